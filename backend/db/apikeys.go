@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	apiKeys = "api_keys"
+
+	// Don't write last_used on every request a busy script makes
+	apiKeyTouchInterval = time.Minute
+)
+
+func EnsureAPIKeyIndexes() {
+	coll := client.Database(db).Collection(apiKeys)
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{"key_hash", 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := coll.Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on api_keys.key_hash: %v", err)
+	}
+}
+
+func CreateAPIKey(ctx context.Context, userID bson.ObjectID, name, prefix, keyHash string, scopes []string) (APIKey, error) {
+	coll, err := scoped(ctx, apiKeys)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	key := APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: keyHash,
+		Scopes:  scopes,
+		Created: time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, key)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	key.ID = result.InsertedID.(bson.ObjectID)
+	return key, nil
+}
+
+// GetAPIKeyByHash finds a key in any club, as it runs before we know who is
+// calling.
+func GetAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error) {
+	coll := client.Database(db).Collection(apiKeys)
+
+	var key APIKey
+	err := coll.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return APIKey{}, fmt.Errorf("api key not found")
+		}
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func GetUserAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	coll, err := scoped(ctx, apiKeys)
+	if err != nil {
+		return nil, err
+	}
+	objID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{"_id", -1}})
+	cursor, err := coll.Find(ctx, bson.M{"user_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []APIKey
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func RevokeAPIKey(ctx context.Context, userID, id string) error {
+	coll, err := scoped(ctx, apiKeys)
+	if err != nil {
+		return err
+	}
+	userObjID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": objID, "user_id": userObjID}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
+
+// TouchAPIKey records that the key was used, at most once a minute.
+func TouchAPIKey(ctx context.Context, id bson.ObjectID, now time.Time) error {
+	coll := client.Database(db).Collection(apiKeys)
+
+	_, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"last_used": bson.M{"$exists": false}},
+			bson.M{"last_used": bson.M{"$lt": now.Add(-apiKeyTouchInterval)}},
+		}},
+		bson.M{"$set": bson.M{"last_used": now}},
+	)
+	return err
+}
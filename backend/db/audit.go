@@ -0,0 +1,294 @@
+package db
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	auditLog = "audit_log"
+
+	maxAuditEntries = 200
+)
+
+// Fields whose values never go into the audit log. A change to them is
+// still recorded, just without the values.
+var redactedFields = map[string]bool{
+	"password":       true,
+	"totp_secret":    true,
+	"recovery_codes": true,
+	"key_hash":       true,
+	"token_hash":     true,
+}
+
+const redacted = "[redacted]"
+
+type actorKey struct{}
+
+type impersonatorKey struct{}
+
+// WithActor returns a copy of ctx whose writes are attributed to the user in
+// the audit log.
+func WithActor(ctx context.Context, userID bson.ObjectID) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+func ActorFromContext(ctx context.Context) (bson.ObjectID, bool) {
+	userID, ok := ctx.Value(actorKey{}).(bson.ObjectID)
+	if !ok || userID.IsZero() {
+		return bson.ObjectID{}, false
+	}
+	return userID, true
+}
+
+// WithImpersonator marks ctx as an admin acting as the actor, so the audit
+// log shows who really made the change.
+func WithImpersonator(ctx context.Context, adminID bson.ObjectID) context.Context {
+	return context.WithValue(ctx, impersonatorKey{}, adminID)
+}
+
+func EnsureAuditIndexes() {
+	coll := client.Database(db).Collection(auditLog)
+	indexModels := []mongo.IndexModel{
+		{Keys: bson.D{{"tenant_id", 1}, {"timestamp", -1}}},
+		{Keys: bson.D{{"tenant_id", 1}, {"entity", 1}, {"entity_id", 1}}},
+		{Keys: bson.D{{"tenant_id", 1}, {"actor_id", 1}}},
+	}
+	_, err := coll.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		log.Printf("Warning: could not create indexes on audit_log: %v", err)
+	}
+}
+
+// audit appends an entry for a change the collection made. before is nil
+// for inserts and after for deletes.
+func (s *scopedCollection) audit(ctx context.Context, action string, id any, before, after bson.M) {
+	entityID, ok := id.(bson.ObjectID)
+	if !ok {
+		return
+	}
+
+	changes := diff(before, after)
+	if len(changes) == 0 {
+		return
+	}
+
+	writeAudit(ctx, s.tenantID, action, s.coll.Name(), entityID, changes)
+}
+
+// RecordAudit appends an entry for an action that isn't a plain write to a
+// club collection, such as an impersonation.
+func RecordAudit(ctx context.Context, action, entity string, entityID bson.ObjectID, changes map[string]Change) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	writeAudit(ctx, tenantID, action, entity, entityID, changes)
+	return nil
+}
+
+// writeAudit inserts an entry attributed to the actor in ctx. Failures are
+// logged rather than returned, as the change itself has already happened.
+func writeAudit(ctx context.Context, tenantID bson.ObjectID, action, entity string, entityID bson.ObjectID, changes map[string]Change) {
+	entry := AuditEntry{
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		Changes:   changes,
+		Timestamp: time.Now(),
+		TenantID:  tenantID,
+	}
+	entry.ActorID, _ = ActorFromContext(ctx)
+	entry.ImpersonatorID, _ = ctx.Value(impersonatorKey{}).(bson.ObjectID)
+
+	// Written directly, the audit log is not itself audited
+	if _, err := client.Database(db).Collection(auditLog).InsertOne(ctx, entry); err != nil {
+		log.Printf("Failed to write audit entry for %s %s: %v", entity, entityID.Hex(), err)
+	}
+}
+
+// snapshot returns the documents matching filter as they are now.
+func (s *scopedCollection) snapshot(ctx context.Context, filter any, limit int64) []bson.M {
+	cursor, err := s.Find(ctx, filter)
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.M
+	for cursor.Next(ctx) && (limit == 0 || int64(len(docs)) < limit) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return docs
+		}
+		docs = append(docs, toMap(doc))
+	}
+	return docs
+}
+
+func (s *scopedCollection) snapshotByID(ctx context.Context, id any) bson.M {
+	docs := s.snapshot(ctx, bson.D{{"_id", id}}, 1)
+	if len(docs) == 0 {
+		return nil
+	}
+	return docs[0]
+}
+
+// diff lists the top level fields that differ between two versions of a
+// document, leaving out fields every document has.
+func diff(before, after bson.M) map[string]Change {
+	changes := map[string]Change{}
+	for _, doc := range []bson.M{before, after} {
+		for key := range doc {
+			if key == "_id" || key == "tenant_id" {
+				continue
+			}
+			from, to := before[key], after[key]
+			if reflect.DeepEqual(from, to) {
+				continue
+			}
+			if redactedFields[key] {
+				from, to = redactValue(from), redactValue(to)
+			}
+			changes[key] = Change{From: from, To: to}
+		}
+	}
+	return changes
+}
+
+func redactValue(value any) any {
+	if value == nil {
+		return nil
+	}
+	return redacted
+}
+
+// toMap converts a document, including nested documents, to maps so entries
+// read back as plain JSON objects.
+func toMap(doc bson.D) bson.M {
+	m := make(bson.M, len(doc))
+	for _, elem := range doc {
+		m[elem.Key] = normalize(elem.Value)
+	}
+	return m
+}
+
+func normalize(value any) any {
+	switch v := value.(type) {
+	case bson.D:
+		return toMap(v)
+	case bson.A:
+		values := make(bson.A, len(v))
+		for i, item := range v {
+			values[i] = normalize(item)
+		}
+		return values
+	}
+	return value
+}
+
+// AuditFilter narrows down GetAuditEntries. Empty fields match everything.
+type AuditFilter struct {
+	ActorID  string
+	Action   string
+	Entity   string
+	EntityID string
+	Since    time.Time
+	Until    time.Time
+	Before   string // Only entries older than this entry, for paging
+	Limit    int64
+}
+
+// GetAuditEntries lists the club's audit log, newest first, with the name
+// and email of whoever made each change.
+func GetAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	coll, err := scoped(ctx, auditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.D{}
+	for key, hex := range map[string]string{"actor_id": filter.ActorID, "entity_id": filter.EntityID} {
+		if hex == "" {
+			continue
+		}
+		objID, err := bson.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, err
+		}
+		match = append(match, bson.E{key, objID})
+	}
+	if filter.Before != "" {
+		objID, err := bson.ObjectIDFromHex(filter.Before)
+		if err != nil {
+			return nil, err
+		}
+		match = append(match, bson.E{"_id", bson.D{{"$lt", objID}}})
+	}
+	if filter.Action != "" {
+		match = append(match, bson.E{"action", filter.Action})
+	}
+	if filter.Entity != "" {
+		match = append(match, bson.E{"entity", filter.Entity})
+	}
+
+	timestamp := bson.D{}
+	if !filter.Since.IsZero() {
+		timestamp = append(timestamp, bson.E{"$gte", filter.Since})
+	}
+	if !filter.Until.IsZero() {
+		timestamp = append(timestamp, bson.E{"$lt", filter.Until})
+	}
+	if len(timestamp) > 0 {
+		match = append(match, bson.E{"timestamp", timestamp})
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > maxAuditEntries {
+		limit = maxAuditEntries
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$sort", bson.D{{"_id", -1}}}},
+		{{"$limit", limit}},
+		{{"$lookup", bson.D{
+			{"from", "users"},
+			{"localField", "actor_id"},
+			{"foreignField", "_id"},
+			{"as", "actorDetails"},
+		}}},
+		{{"$addFields", bson.D{
+			{"actor_name", bson.D{
+				{"$arrayElemAt", bson.A{"$actorDetails.name", 0}},
+			}},
+			{"actor_email", bson.D{
+				{"$arrayElemAt", bson.A{"$actorDetails.email", 0}},
+			}},
+		}}},
+		{{"$project", bson.D{
+			{"actorDetails", 0},
+		}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AuditEntry
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	for _, entry := range results {
+		for key, change := range entry.Changes {
+			entry.Changes[key] = Change{From: normalize(change.From), To: normalize(change.To)}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	clubs = "clubs"
+
+	defaultClubSlug = "default"
+)
+
+// Collections whose documents belong to a single club
+var tenantCollections = []string{players, teams, fixtures, users, memberships, apiKeys, playerClaims, invites, opponents, seasons, competitions}
+
+func EnsureClubIndexes() {
+	coll := client.Database(db).Collection(clubs)
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{"slug", 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := coll.Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on clubs.slug: %v", err)
+	}
+
+	for _, name := range tenantCollections {
+		_, err := client.Database(db).Collection(name).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+			Keys: bson.D{{"tenant_id", 1}},
+		})
+		if err != nil {
+			log.Printf("Warning: could not create tenant index on %s: %v", name, err)
+		}
+	}
+}
+
+// Slugify turns a club name into the identifier used at registration.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+func CreateClub(ctx context.Context, name, slug string) (Club, error) {
+	coll := client.Database(db).Collection(clubs)
+
+	club := Club{
+		Name:    name,
+		Slug:    slug,
+		Created: time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, club)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Club{}, fmt.Errorf("a club with this name already exists")
+		}
+		return Club{}, err
+	}
+
+	club.ID = result.InsertedID.(bson.ObjectID)
+	return club, nil
+}
+
+func GetClubBySlug(ctx context.Context, slug string) (Club, error) {
+	coll := client.Database(db).Collection(clubs)
+
+	var club Club
+	err := coll.FindOne(ctx, bson.M{"slug": slug}).Decode(&club)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Club{}, fmt.Errorf("club not found")
+		}
+		return Club{}, err
+	}
+	return club, nil
+}
+
+func GetClubByID(ctx context.Context, id bson.ObjectID) (Club, error) {
+	coll := client.Database(db).Collection(clubs)
+
+	var club Club
+	err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&club)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Club{}, fmt.Errorf("club not found")
+		}
+		return Club{}, err
+	}
+	return club, nil
+}
+
+func DeleteClub(ctx context.Context, id bson.ObjectID) error {
+	coll := client.Database(db).Collection(clubs)
+	_, err := coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// DefaultClubSlug is the club that registrations without a club join.
+func DefaultClubSlug() string {
+	if slug := os.Getenv("DEFAULT_CLUB_SLUG"); slug != "" {
+		return slug
+	}
+	return defaultClubSlug
+}
+
+// MigrateDefaultTenant makes sure the default club exists and hands it every
+// document written before clubs existed.
+func MigrateDefaultTenant() {
+	ctx := context.TODO()
+
+	club, err := GetClubBySlug(ctx, DefaultClubSlug())
+	if err != nil {
+		club, err = CreateClub(ctx, "Default Club", DefaultClubSlug())
+		if err != nil {
+			log.Printf("Warning: could not create default club: %v", err)
+			return
+		}
+	}
+
+	for _, name := range tenantCollections {
+		result, err := client.Database(db).Collection(name).UpdateMany(
+			ctx,
+			bson.M{"tenant_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"tenant_id": club.ID}},
+		)
+		if err != nil {
+			log.Printf("Warning: could not migrate %s to default club: %v", name, err)
+			continue
+		}
+		if result.ModifiedCount > 0 {
+			log.Printf("Moved %d %s into club %s", result.ModifiedCount, name, club.Slug)
+		}
+	}
+}
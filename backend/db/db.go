@@ -2,17 +2,20 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"regexp"
 	"strconv"
 
 	"time"
 
+	"fctracker/passwords"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -23,6 +26,24 @@ const (
 	users    = "users"
 )
 
+var passwordHasher *passwords.Hasher
+
+// The default hasher is in place until SetPasswordHasher is called. Broken
+// defaults stop the server at startup rather than failing every login.
+func init() {
+	hasher, err := passwords.NewHasher(passwords.DefaultParams)
+	if err != nil {
+		log.Fatalf("Invalid default password hashing parameters: %v", err)
+	}
+	passwordHasher = hasher
+}
+
+// SetPasswordHasher changes how new passwords are hashed. Existing hashes
+// keep verifying and are upgraded as users log in.
+func SetPasswordHasher(h *passwords.Hasher) {
+	passwordHasher = h
+}
+
 func EnsureUserIndexes() {
 	coll := client.Database(db).Collection(users)
 	indexModel := mongo.IndexModel{
@@ -33,30 +54,47 @@ func EnsureUserIndexes() {
 	if err != nil {
 		log.Printf("Warning: could not create unique index on users.email: %v", err)
 	}
+
+	// Users without a linked provider account are left out so they don't
+	// collide on the missing field
+	identityIndex := mongo.IndexModel{
+		Keys: bson.D{{"identities.issuer", 1}, {"identities.subject", 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"identities": bson.M{"$exists": true},
+		}),
+	}
+	_, err = coll.Indexes().CreateOne(context.TODO(), identityIndex)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on users.identities: %v", err)
+	}
 }
 
-func CreateUser(email, password, name string) (User, error) {
+// CreateUser adds an account to a club. Emails are unique across clubs so a
+// login can find the user before we know which club they belong to.
+func CreateUser(ctx context.Context, email, password, name, role string, tenantID bson.ObjectID) (User, error) {
 	coll := client.Database(db).Collection(users)
 
 	var existing User
-	err := coll.FindOne(context.TODO(), bson.M{"email": email}).Decode(&existing)
+	err := coll.FindOne(ctx, bson.M{"email": email}).Decode(&existing)
 	if err == nil {
 		return User{}, fmt.Errorf("a user with this email already exists")
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := passwordHasher.Hash(password)
 	if err != nil {
 		return User{}, fmt.Errorf("failed to hash password")
 	}
 
 	user := User{
 		Email:    email,
-		Password: string(hash),
+		Password: hash,
 		Name:     name,
+		Role:     role,
+		TenantID: tenantID,
 		Created:  time.Now().Format(format),
 	}
 
-	result, err := coll.InsertOne(context.TODO(), user)
+	result, err := coll.InsertOne(ctx, user)
 	if err != nil {
 		return User{}, err
 	}
@@ -65,11 +103,108 @@ func CreateUser(email, password, name string) (User, error) {
 	return user, nil
 }
 
-func GetUserByEmail(email string) (User, error) {
+// GetUserByEmail looks a user up in any club. It is meant for the auth flows
+// that run before a club is known; use GetClubUserByEmail everywhere else.
+func GetUserByEmail(ctx context.Context, email string) (User, error) {
+	coll := client.Database(db).Collection(users)
+
+	var user User
+	err := coll.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, fmt.Errorf("user not found")
+		}
+		return User{}, err
+	}
+	return user, nil
+}
+
+// GetUserByIdentity finds the user linked to a provider account, in any club.
+func GetUserByIdentity(ctx context.Context, issuer, subject string) (User, error) {
+	coll := client.Database(db).Collection(users)
+
+	var user User
+	err := coll.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}},
+	}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, fmt.Errorf("user not found")
+		}
+		return User{}, err
+	}
+	return user, nil
+}
+
+// LinkUserIdentity lets the provider account log in as the user. Linking
+// proves the user owns the email, so it is marked verified too.
+func LinkUserIdentity(ctx context.Context, id bson.ObjectID, identity Identity) error {
+	coll := client.Database(db).Collection(users)
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$addToSet": bson.M{"identities": identity},
+		"$set":      bson.M{"verified": true},
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("this account is already linked to another user")
+		}
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func GetClubUserByEmail(ctx context.Context, email string) (User, error) {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return User{}, err
+	}
+
+	var user User
+	err = coll.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, fmt.Errorf("user not found")
+		}
+		return User{}, err
+	}
+	return user, nil
+}
+
+func GetClubUserByID(ctx context.Context, id string) (User, error) {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return User{}, err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return User{}, err
+	}
+
+	var user User
+	err = coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, fmt.Errorf("user not found")
+		}
+		return User{}, err
+	}
+	return user, nil
+}
+
+// GetUserByID looks a user up in any club, see GetUserByEmail.
+func GetUserByID(ctx context.Context, id string) (User, error) {
 	coll := client.Database(db).Collection(users)
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return User{}, err
+	}
 
 	var user User
-	err := coll.FindOne(context.TODO(), bson.M{"email": email}).Decode(&user)
+	err = coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return User{}, fmt.Errorf("user not found")
@@ -79,23 +214,342 @@ func GetUserByEmail(email string) (User, error) {
 	return user, nil
 }
 
-func CheckPassword(hashedPassword, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+func SetUserRole(ctx context.Context, id, role string) error {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func SetUserRoleByEmail(ctx context.Context, email, role string) error {
+	coll := client.Database(db).Collection(users)
+
+	result, err := coll.UpdateOne(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// SetUserPassword replaces a user's password. Like GetUserByID it works
+// across clubs because resets happen before login.
+func SetUserPassword(ctx context.Context, id bson.ObjectID, password string) error {
+	coll := client.Database(db).Collection(users)
+
+	hash, err := passwordHasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password")
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"password": hash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// SetUserVerified marks the user's email as confirmed. It works across clubs
+// because verification links are opened before login.
+func SetUserVerified(ctx context.Context, id bson.ObjectID) error {
+	coll := client.Database(db).Collection(users)
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"verified": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// SetUserTOTPSecret stores a pending secret. Two-factor stays off until
+// EnableUserTOTP is called.
+func SetUserTOTPSecret(ctx context.Context, id bson.ObjectID, secret string) error {
+	coll := client.Database(db).Collection(users)
+
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id, "totp_enabled": bson.M{"$ne": true}}, bson.M{
+		"$set": bson.M{"totp_secret": secret},
+	})
+	return err
+}
+
+func EnableUserTOTP(ctx context.Context, id bson.ObjectID, step int64, recoveryCodeHashes []string) error {
+	coll := client.Database(db).Collection(users)
+
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+			"recovery_codes": recoveryCodeHashes,
+		},
+	})
+	return err
+}
+
+func DisableUserTOTP(ctx context.Context, id bson.ObjectID) error {
+	coll := client.Database(db).Collection(users)
+
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"totp_enabled": false},
+		"$unset": bson.M{"totp_secret": "", "totp_last_step": "", "recovery_codes": ""},
+	})
+	return err
+}
+
+// ClaimTOTPStep records the time step of an accepted code. It fails when the
+// step was already used so a code can't be replayed.
+func ClaimTOTPStep(ctx context.Context, id bson.ObjectID, step int64) error {
+	coll := client.Database(db).Collection(users)
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"totp_last_step": bson.M{"$lt": step}},
+			bson.M{"totp_last_step": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"totp_last_step": step}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("code already used")
+	}
+	return nil
+}
+
+// UseRecoveryCode removes a recovery code so it only works once.
+func UseRecoveryCode(ctx context.Context, id bson.ObjectID, codeHash string) error {
+	coll := client.Database(db).Collection(users)
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("invalid recovery code")
+	}
+	return nil
+}
+
+// UpdateUserProfile changes the user's name and email. A new email has to
+// be confirmed again, so the user is marked unverified when it changes.
+func UpdateUserProfile(ctx context.Context, id bson.ObjectID, name, email string) (User, error) {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return User{}, err
+	}
+
+	var current User
+	err = coll.FindOne(ctx, bson.M{"_id": id}).Decode(&current)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, fmt.Errorf("user not found")
+		}
+		return User{}, err
+	}
+
+	set := bson.M{"name": name, "email": email}
+	if email != current.Email {
+		set["verified"] = false
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var user User
+	err = coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set}, opts).Decode(&user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return User{}, fmt.Errorf("a user with this email already exists")
+		}
+		return User{}, err
+	}
+	return user, nil
+}
+
+// ListClubUsers returns a page of the club's users sorted by name, and how
+// many users match in total. search matches part of the name or email.
+func ListClubUsers(ctx context.Context, search string, page, limit int64) ([]User, int64, error) {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filter := bson.M{}
+	if search != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"name": pattern},
+			bson.M{"email": pattern},
+		}
+	}
+
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{"name", 1}, {"_id", 1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []User
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// SetUserDisabled blocks or restores an account in the club in ctx.
+func SetUserDisabled(ctx context.Context, id bson.ObjectID, disabled bool) error {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"disabled": disabled}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// CountClubAdmins counts the admins of the club in ctx.
+func CountClubAdmins(ctx context.Context) (int64, error) {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return 0, err
+	}
+	return coll.CountDocuments(ctx, bson.M{"role": RoleAdmin})
+}
+
+// ErrLastAdmin is returned when a change would leave the club without an
+// admin.
+var ErrLastAdmin = errors.New("the club needs at least one admin")
+
+// DemoteAdmin gives an admin of the club in ctx another role, unless they
+// are its last admin. Counting first would let two admins demote each other
+// at once, each counting the other, so the demotion is made first and undone
+// when no admin is left.
+func DemoteAdmin(ctx context.Context, id bson.ObjectID, role string) error {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": id, "role": RoleAdmin}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("admin not found")
+	}
+
+	admins, err := coll.CountDocuments(ctx, bson.M{"role": RoleAdmin})
+	if err == nil && admins > 0 {
+		return nil
+	}
+	if _, restoreErr := coll.UpdateOne(ctx, bson.M{"_id": id, "role": role}, bson.M{"$set": bson.M{"role": RoleAdmin}}); restoreErr != nil {
+		return restoreErr
+	}
+	if err != nil {
+		return err
+	}
+	return ErrLastAdmin
+}
+
+// DeleteUser removes the user along with their team memberships, API keys,
+// player claims, sessions and tokens. Players and fixtures stay with the club, but a
+// linked player is unlinked.
+func DeleteUser(ctx context.Context, id bson.ObjectID) error {
+	coll, err := scoped(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	for _, name := range []string{memberships, apiKeys, playerClaims, sessions, refreshTokens, userTokens} {
+		_, err := client.Database(db).Collection(name).DeleteMany(ctx, bson.M{"user_id": id})
+		if err != nil {
+			log.Printf("Failed to delete %s of user %s: %v", name, id.Hex(), err)
+		}
+	}
+
+	_, err = client.Database(db).Collection(players).UpdateMany(ctx,
+		bson.M{"user_id": id},
+		bson.M{"$unset": bson.M{"user_id": ""}},
+	)
+	if err != nil {
+		log.Printf("Failed to unlink player of user %s: %v", id.Hex(), err)
+	}
+	return nil
 }
 
-func SeedPlayers() error {
-	collPlayers := client.Database(db).Collection(players)
-	collTeams := client.Database(db).Collection(teams)
+// CheckPassword reports whether password matches the stored hash, and
+// whether the hash is outdated and should be replaced with SetUserPassword.
+func CheckPassword(hashedPassword, password string) (ok, rehash bool) {
+	ok, rehash, err := passwordHasher.Verify(hashedPassword, password)
+	if err != nil {
+		log.Printf("Failed to verify password hash: %v", err)
+		return false, false
+	}
+	return ok, rehash
+}
+
+func SeedPlayers(ctx context.Context) error {
+	collPlayers, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
+	collTeams, err := scoped(ctx, teams)
+	if err != nil {
+		return err
+	}
 
 	// Create a team
 	team := Team{
 		Name:    "Seeded FC",
 		Coach:   "Coach Random",
 		Players: []bson.ObjectID{},
-		Founded: "2024",
+		Founded: 2024,
 	}
-	teamResult, err := collTeams.InsertOne(context.TODO(), team)
+	teamResult, err := collTeams.InsertOne(ctx, team)
 	if err != nil {
 		return err
 	}
@@ -109,18 +563,15 @@ func SeedPlayers() error {
 	for i := 0; i < 10; i++ {
 		age := rand.Intn(10) + 18 // 18-27
 		player := Player{
-			Name:          names[i%len(names)],
-			Age:           strconv.Itoa(age),
-			Position:      positions[i%len(positions)],
-			FunFact:       funFacts[i%len(funFacts)],
-			Goals:         rand.Intn(20),
-			Assists:       rand.Intn(10),
-			GamesPlayed:   rand.Intn(30) + 1,
-			ManOfTheMatch: rand.Intn(5),
-			Active:        true,
-			TeamID:        teamID,
+			Name:        names[i%len(names)],
+			DOB:         time.Now().AddDate(-age, 0, -rand.Intn(365)).Truncate(24 * time.Hour),
+			Position:    positions[i%len(positions)],
+			FunFact:     funFacts[i%len(funFacts)],
+			GamesPlayed: rand.Intn(30) + 1, // Goals and awards come from fixtures
+			Active:      true,
+			TeamID:      teamID,
 		}
-		res, err := collPlayers.InsertOne(context.TODO(), player)
+		res, err := collPlayers.InsertOne(ctx, player)
 		if err != nil {
 			return err
 		}
@@ -128,7 +579,7 @@ func SeedPlayers() error {
 	}
 
 	// Optionally update the team with the player IDs
-	_, err = collTeams.UpdateByID(context.TODO(), teamID, bson.M{"$set": bson.M{"players": playerIDs}})
+	_, err = collTeams.UpdateByID(ctx, teamID, bson.M{"$set": bson.M{"players": playerIDs}})
 	if err != nil {
 		return err
 	}
@@ -136,8 +587,11 @@ func SeedPlayers() error {
 	return nil
 }
 
-func GetActivePlayers() ([]Player, error) {
-	coll := client.Database(db).Collection(players)
+func GetActivePlayers(ctx context.Context) ([]Player, error) {
+	coll, err := scoped(ctx, players)
+	if err != nil {
+		return nil, err
+	}
 
 	pipeline := mongo.Pipeline{
 		{{"$match", bson.D{{"active", true}}}},
@@ -156,28 +610,44 @@ func GetActivePlayers() ([]Player, error) {
 		}}},
 	}
 
-	cursor, err := coll.Aggregate(context.TODO(), pipeline)
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []Player
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
 	return results, nil
 }
 
-func GetPlayerByID(id string) (Player, error) {
-	coll := client.Database(db).Collection(players)
+func GetPlayerByID(ctx context.Context, id string) (Player, error) {
 	objID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
 		return Player{}, err
 	}
+	return getPlayer(ctx, bson.D{{"_id", objID}})
+}
+
+// GetPlayerByUserID returns the player linked to the user's account.
+func GetPlayerByUserID(ctx context.Context, userID string) (Player, error) {
+	objID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return Player{}, err
+	}
+	return getPlayer(ctx, bson.D{{"user_id", objID}})
+}
+
+func getPlayer(ctx context.Context, match bson.D) (Player, error) {
+	coll, err := scoped(ctx, players)
+	if err != nil {
+		return Player{}, err
+	}
 
 	pipeline := mongo.Pipeline{
-		{{"$match", bson.D{{"_id", objID}}}},
+		{{"$match", match}},
 		{{"$lookup", bson.D{
 			{"from", "teams"},
 			{"localField", "team_id"},
@@ -196,12 +666,12 @@ func GetPlayerByID(id string) (Player, error) {
 		}}},
 	}
 
-	cursor, err := coll.Aggregate(context.TODO(), pipeline)
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return Player{}, err
 	}
 	var results []Player
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return Player{}, err
 	}
 	if len(results) == 0 {
@@ -210,8 +680,11 @@ func GetPlayerByID(id string) (Player, error) {
 	return results[0], nil
 }
 
-func GetPlayerFixtures(playerID string) ([]Fixture, error) {
-	coll := client.Database(db).Collection(fixtures)
+func GetPlayerFixtures(ctx context.Context, playerID string, filter FixtureFilter) ([]Fixture, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return nil, err
+	}
 	objID, err := bson.ObjectIDFromHex(playerID)
 	if err != nil {
 		return nil, err
@@ -226,37 +699,28 @@ func GetPlayerFixtures(playerID string) ([]Fixture, error) {
 				bson.D{{"lineup", objID}},
 			}},
 		}}},
-		{{"$lookup", bson.D{
-			{"from", "players"},
-			{"localField", "man_of_the_match"},
-			{"foreignField", "_id"},
-			{"as", "motmDetails"},
-		}}},
-		{{"$addFields", bson.D{
-			{"man_of_the_match_name", bson.D{
-				{"$arrayElemAt", bson.A{"$motmDetails.name", 0}},
-			}},
-		}}},
-		{{"$project", bson.D{
-			{"motmDetails", 0},
-		}}},
-		{{"$sort", bson.D{{"date", -1}}}},
+		{{"$match", filter.match()}},
 	}
+	pipeline = append(pipeline, fixtureDetails()...)
+	pipeline = append(pipeline, bson.D{{"$sort", bson.D{{"date", -1}}}})
 
-	cursor, err := coll.Aggregate(context.TODO(), pipeline)
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []Fixture
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 	return results, nil
 }
 
-func AddPlayer(name, position, fact, age, teamID string) (Player, error) {
-	coll := client.Database(db).Collection(players)
+func AddPlayer(ctx context.Context, name, position, fact string, dob time.Time, teamID string) (Player, error) {
+	coll, err := scoped(ctx, players)
+	if err != nil {
+		return Player{}, err
+	}
 
 	// Get team from name
 	objID, err := bson.ObjectIDFromHex(teamID)
@@ -264,9 +728,9 @@ func AddPlayer(name, position, fact, age, teamID string) (Player, error) {
 		return Player{}, err
 	}
 
-	player := newPlayer(name, position, fact, age, objID)
+	player := newPlayer(name, position, fact, dob, objID)
 
-	_, err = coll.InsertOne(context.TODO(), player)
+	_, err = coll.InsertOne(ctx, player)
 	if err != nil {
 		return player, err
 	}
@@ -274,8 +738,11 @@ func AddPlayer(name, position, fact, age, teamID string) (Player, error) {
 	return player, nil
 }
 
-func UpdatePlayerByID(id string, update map[string]any) (any, error) {
-	coll := client.Database(db).Collection(players)
+func UpdatePlayerByID(ctx context.Context, id string, update map[string]any) (any, error) {
+	coll, err := scoped(ctx, players)
+	if err != nil {
+		return nil, err
+	}
 	objID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
@@ -283,15 +750,18 @@ func UpdatePlayerByID(id string, update map[string]any) (any, error) {
 	filter := bson.D{{"_id", objID}}
 	updateDoc := bson.M{"$set": update}
 
-	result, err := coll.UpdateOne(context.TODO(), filter, updateDoc)
+	result, err := coll.UpdateOne(ctx, filter, updateDoc)
 	if err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func DeletePlayer(id string) error {
-	coll := client.Database(db).Collection(players)
+func DeletePlayer(ctx context.Context, id string) error {
+	coll, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
 	objID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
 		return err
@@ -300,7 +770,7 @@ func DeletePlayer(id string) error {
 
 	// Check if player exists
 	var result Player
-	err = coll.FindOne(context.TODO(), filter).Decode(&result)
+	err = coll.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return fmt.Errorf("player not found")
@@ -309,30 +779,37 @@ func DeletePlayer(id string) error {
 	}
 
 	// Player exists, proceed to delete
-	_, err = coll.DeleteOne(context.TODO(), filter)
+	_, err = coll.DeleteOne(ctx, filter)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func AddTeam(name, coach, founded string) (Team, error) {
-	coll := client.Database(db).Collection(teams)
+func AddTeam(ctx context.Context, name, coach string, founded int) (Team, error) {
+	coll, err := scoped(ctx, teams)
+	if err != nil {
+		return Team{}, err
+	}
 
 	team := newTeam(name, coach, founded)
 
-	_, err := coll.InsertOne(context.TODO(), team)
+	result, err := coll.InsertOne(ctx, team)
 	if err != nil {
 		return team, err
 	}
 
+	team.ID = result.InsertedID.(bson.ObjectID)
 	return team, nil
 }
 
-func GetTeamById(id string) (Team, error) {
+func GetTeamById(ctx context.Context, id string) (Team, error) {
 	var result Team
 
-	coll := client.Database(db).Collection(teams)
+	coll, err := scoped(ctx, teams)
+	if err != nil {
+		return Team{}, err
+	}
 	objID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
 		return result, err
@@ -340,7 +817,7 @@ func GetTeamById(id string) (Team, error) {
 	filter := bson.D{{"_id", objID}}
 
 	// Check if team exists
-	err = coll.FindOne(context.TODO(), filter).Decode(&result)
+	err = coll.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return result, fmt.Errorf("player not found")
@@ -350,33 +827,39 @@ func GetTeamById(id string) (Team, error) {
 	return result, nil
 }
 
-func GetAllTeams() ([]Team, error) {
-	coll := client.Database(db).Collection(teams)
+func GetAllTeams(ctx context.Context) ([]Team, error) {
+	coll, err := scoped(ctx, teams)
+	if err != nil {
+		return nil, err
+	}
 
 	filter := bson.D{}
 
-	cursor, err := coll.Find(context.TODO(), filter)
+	cursor, err := coll.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []Team
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
 	return results, nil
 }
 
-func GetTeamByName(name string) (Team, error) {
+func GetTeamByName(ctx context.Context, name string) (Team, error) {
 	var result Team
 
-	coll := client.Database(db).Collection(teams)
+	coll, err := scoped(ctx, teams)
+	if err != nil {
+		return Team{}, err
+	}
 
 	filter := bson.D{{"name", name}}
 
 	// Check if team exists
-	err := coll.FindOne(context.TODO(), filter).Decode(&result)
+	err = coll.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return result, fmt.Errorf("team not found")
@@ -386,17 +869,42 @@ func GetTeamByName(name string) (Team, error) {
 	return result, nil
 }
 
-func AddFixture(date, homeTeam, awayTeam, homeScore, awayScore, manOfTheMatch, latitude, longitude string) (Fixture, error) {
+func AddFixture(ctx context.Context, date time.Time, homeTeam, awayTeam bson.ObjectID, homeScore, awayScore *int, manOfTheMatch, latitude, longitude string, seasonID, competitionID bson.ObjectID) (Fixture, error) {
 	var result Fixture
 
-	coll := client.Database(db).Collection(fixtures)
-
-	motmId, err := bson.ObjectIDFromHex(manOfTheMatch)
+	coll, err := scoped(ctx, fixtures)
 	if err != nil {
-		return result, err
+		return Fixture{}, err
 	}
 
-	result = newFixture(date, homeTeam, awayTeam, homeScore, awayScore, motmId)
+	// Upcoming games have no result yet. Fixtures entered with both scores
+	// are treated as already played.
+	status := FixtureScheduled
+	if homeScore != nil && awayScore != nil {
+		status = FixtureCompleted
+	} else if homeScore != nil || awayScore != nil {
+		return result, fmt.Errorf("both scores are needed for a result")
+	}
+
+	var motmId bson.ObjectID
+	if manOfTheMatch != "" {
+		if status != FixtureCompleted {
+			return result, fmt.Errorf("man of the match can only be given with a result")
+		}
+		motmId, err = bson.ObjectIDFromHex(manOfTheMatch)
+		if err != nil {
+			return result, err
+		}
+
+		// Man of the match must be one of the club's own players
+		if _, err := GetPlayerByID(ctx, manOfTheMatch); err != nil {
+			return result, err
+		}
+	}
+
+	result = newFixture(date, status, homeTeam, awayTeam, homeScore, awayScore, motmId)
+	result.SeasonID = seasonID
+	result.CompetitionID = competitionID
 
 	// Add location if coordinates are provided
 	if latitude != "" && longitude != "" {
@@ -412,17 +920,24 @@ func AddFixture(date, homeTeam, awayTeam, homeScore, awayScore, manOfTheMatch, l
 		}
 	}
 
-	_, err = coll.InsertOne(context.TODO(), result)
+	inserted, err := coll.InsertOne(ctx, result)
 	if err != nil {
 		return result, err
 	}
+	result.ID = inserted.InsertedID.(bson.ObjectID)
+
+	if !motmId.IsZero() {
+		if err := RecountPlayerStats(ctx, motmId); err != nil {
+			return result, err
+		}
+	}
 
 	return result, nil
 }
 
-func GetFixtures() ([]Fixture, error) {
-	coll := client.Database(db).Collection(fixtures)
-
+// fixtureDetails resolves the names of the teams and the man of the match.
+// Each side is either one of the club's teams or an opponent.
+func fixtureDetails() mongo.Pipeline {
 	pipeline := mongo.Pipeline{
 		{{"$lookup", bson.D{
 			{"from", "players"},
@@ -430,32 +945,82 @@ func GetFixtures() ([]Fixture, error) {
 			{"foreignField", "_id"},
 			{"as", "motmDetails"},
 		}}},
-		{{"$addFields", bson.D{
-			{"man_of_the_match_name", bson.D{
-				{"$arrayElemAt", bson.A{"$motmDetails.name", 0}},
-			}},
-		}}},
-		{{"$project", bson.D{
-			{"motmDetails", 0},
-		}}},
 	}
 
-	cursor, err := coll.Aggregate(context.TODO(), pipeline)
+	project := bson.D{{"motmDetails", 0}}
+	names := bson.D{
+		{"man_of_the_match_name", bson.D{
+			{"$arrayElemAt", bson.A{"$motmDetails.name", 0}},
+		}},
+	}
+	for _, side := range []string{"home", "away"} {
+		pipeline = append(pipeline,
+			bson.D{{"$lookup", bson.D{
+				{"from", teams},
+				{"localField", side + "_team_id"},
+				{"foreignField", "_id"},
+				{"as", side + "TeamDetails"},
+			}}},
+			bson.D{{"$lookup", bson.D{
+				{"from", opponents},
+				{"localField", side + "_team_id"},
+				{"foreignField", "_id"},
+				{"as", side + "OpponentDetails"},
+			}}},
+		)
+		names = append(names,
+			// Falls back to the stored name of a fixture not yet migrated
+			bson.E{side + "_team", bson.D{{"$ifNull", bson.A{
+				bson.D{{"$arrayElemAt", bson.A{"$" + side + "TeamDetails.name", 0}}},
+				bson.D{{"$arrayElemAt", bson.A{"$" + side + "OpponentDetails.name", 0}}},
+				"$" + side + "_team",
+			}}}},
+			bson.E{side + "_is_opponent", bson.D{{"$gt", bson.A{
+				bson.D{{"$size", "$" + side + "OpponentDetails"}}, 0,
+			}}}},
+		)
+		project = append(project, bson.E{side + "TeamDetails", 0}, bson.E{side + "OpponentDetails", 0})
+	}
+
+	return append(pipeline,
+		bson.D{{"$addFields", names}},
+		bson.D{{"$project", project}},
+	)
+}
+
+func GetFixtures(ctx context.Context, filter FixtureFilter) ([]Fixture, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", filter.match()}},
+	}
+	pipeline = append(pipeline, fixtureDetails()...)
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []Fixture
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
 	return results, nil
 }
 
-func GetFixtureByID(id string) (Fixture, error) {
+// ErrFixtureNotFound is returned when the club has no fixture with the ID.
+var ErrFixtureNotFound = errors.New("fixture not found")
+
+func GetFixtureByID(ctx context.Context, id string) (Fixture, error) {
 	var result Fixture
-	coll := client.Database(db).Collection(fixtures)
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return Fixture{}, err
+	}
 	objID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
 		return result, err
@@ -463,88 +1028,177 @@ func GetFixtureByID(id string) (Fixture, error) {
 
 	pipeline := mongo.Pipeline{
 		{{"$match", bson.D{{"_id", objID}}}},
-		{{"$lookup", bson.D{
-			{"from", "players"},
-			{"localField", "man_of_the_match"},
-			{"foreignField", "_id"},
-			{"as", "motmDetails"},
-		}}},
-		{{"$addFields", bson.D{
-			{"man_of_the_match_name", bson.D{
-				{"$arrayElemAt", bson.A{"$motmDetails.name", 0}},
-			}},
-		}}},
-		{{"$project", bson.D{
-			{"motmDetails", 0},
-		}}},
 	}
+	pipeline = append(pipeline, fixtureDetails()...)
 
-	cursor, err := coll.Aggregate(context.TODO(), pipeline)
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return result, err
 	}
 	var fixtures []Fixture
-	if err = cursor.All(context.TODO(), &fixtures); err != nil {
+	if err = cursor.All(ctx, &fixtures); err != nil {
 		return result, err
 	}
 	if len(fixtures) == 0 {
-		return result, fmt.Errorf("fixture not found")
+		return result, ErrFixtureNotFound
 	}
 	return fixtures[0], nil
 }
 
-func GetLeaderboard(stat string) ([]Player, error) {
-	coll := client.Database(db).Collection(players)
-	opts := options.Find().SetSort(bson.D{{stat, -1}}).SetLimit(5)
-	cursor, err := coll.Find(context.TODO(), bson.D{}, opts)
+// Fixture field each leaderboard stat is counted from
+var leaderboardStats = map[string]string{
+	"goals":            "goal_scorers",
+	"assists":          "assist_scorers",
+	"man_of_the_match": "man_of_the_match",
+}
+
+// GetLeaderboard ranks the top five players for a stat. The totals are
+// counted from the completed fixtures the filter selects, and replace the
+// player's all-time total in the results.
+func GetLeaderboard(ctx context.Context, stat string, filter FixtureFilter) ([]Player, error) {
+	coll, err := scoped(ctx, fixtures)
 	if err != nil {
 		return nil, err
 	}
-	var results []Player
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		return nil, err
-	}
-	return results, nil
-}
 
-func GetLeaderboardFixtures() ([]Fixture, error) {
-	coll := client.Database(db).Collection(fixtures)
+	field, ok := leaderboardStats[stat]
+	if !ok {
+		return nil, fmt.Errorf("unknown stat %s", stat)
+	}
 
 	pipeline := mongo.Pipeline{
+		{{"$match", completedFixture}},
+		{{"$match", filter.match()}},
+		{{"$unwind", "$" + field}},
+		{{"$group", bson.D{
+			{"_id", "$" + field},
+			{"count", bson.D{{"$sum", 1}}},
+		}}},
+		{{"$sort", bson.D{{"count", -1}, {"_id", 1}}}},
+		{{"$limit", 5}},
 		{{"$lookup", bson.D{
 			{"from", "players"},
-			{"localField", "man_of_the_match"},
+			{"localField", "_id"},
 			{"foreignField", "_id"},
-			{"as", "motmDetails"},
+			{"as", "player"},
 		}}},
-		{{"$addFields", bson.D{
-			{"man_of_the_match_name", bson.D{
-				{"$arrayElemAt", bson.A{"$motmDetails.name", 0}},
+		{{"$unwind", "$player"}},
+		{{"$replaceRoot", bson.D{
+			{"newRoot", bson.D{
+				{"$mergeObjects", bson.A{"$player", bson.D{{stat, "$count"}}}},
 			}},
 		}}},
-		{{"$project", bson.D{
-			{"motmDetails", 0},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := []Player{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// PlayerStats are a player's totals over a set of completed fixtures.
+type PlayerStats struct {
+	Appearances   int `bson:"appearances" json:"appearances"`
+	Goals         int `bson:"goals" json:"goals"`
+	Assists       int `bson:"assists" json:"assists"`
+	ManOfTheMatch int `bson:"man_of_the_match" json:"man_of_the_match"`
+}
+
+// GetPlayerStats counts the player's stats in the completed fixtures the
+// filter selects.
+func GetPlayerStats(ctx context.Context, playerID string, filter FixtureFilter) (PlayerStats, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+	objID, err := bson.ObjectIDFromHex(playerID)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+
+	// Occurrences of the player in a fixture field, which may be a list
+	occurrences := func(field string) bson.D {
+		return bson.D{{"$size", bson.D{
+			{"$filter", bson.D{
+				{"input", bson.D{{"$ifNull", bson.A{"$" + field, bson.A{}}}}},
+				{"as", "id"},
+				{"cond", bson.D{{"$eq", bson.A{"$$id", objID}}}},
+			}},
+		}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", completedFixture}},
+		{{"$match", filter.match()}},
+		{{"$group", bson.D{
+			{"_id", nil},
+			{"appearances", bson.D{{"$sum", bson.D{{"$min", bson.A{occurrences("lineup"), 1}}}}}},
+			{"goals", bson.D{{"$sum", occurrences("goal_scorers")}}},
+			{"assists", bson.D{{"$sum", occurrences("assist_scorers")}}},
+			{"man_of_the_match", bson.D{{"$sum", bson.D{
+				{"$cond", bson.A{bson.D{{"$eq", bson.A{"$man_of_the_match", objID}}}, 1, 0}},
+			}}}},
 		}}},
-		{{"$sort", bson.D{{"date", -1}}}},
-		{{"$limit", 5}},
 	}
 
-	cursor, err := coll.Aggregate(context.TODO(), pipeline)
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats PlayerStats
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&stats); err != nil {
+			return PlayerStats{}, err
+		}
+	}
+	return stats, cursor.Err()
+}
+
+func GetLeaderboardFixtures(ctx context.Context, filter FixtureFilter) ([]Fixture, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", completedFixture}},
+		{{"$match", filter.match()}},
+	}
+	pipeline = append(pipeline, fixtureDetails()...)
+	pipeline = append(pipeline,
+		bson.D{{"$sort", bson.D{{"date", -1}}}},
+		bson.D{{"$limit", 5}},
+	)
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []Fixture
-	if err = cursor.All(context.TODO(), &results); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 	return results, nil
 }
 
 // 1. Add goalscorer to fixture
-func AddGoalscorerToFixtureOnly(fixtureID, playerID string) error {
-	collFixtures := client.Database(db).Collection(fixtures)
-	collPlayers := client.Database(db).Collection(players)
+func AddGoalscorerToFixtureOnly(ctx context.Context, fixtureID, playerID string) error {
+	collFixtures, err := scoped(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+	collPlayers, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
 
 	fixtureObjID, err := bson.ObjectIDFromHex(fixtureID)
 	if err != nil {
@@ -557,7 +1211,7 @@ func AddGoalscorerToFixtureOnly(fixtureID, playerID string) error {
 
 	// Get player name
 	var player Player
-	err = collPlayers.FindOne(context.TODO(), bson.M{"_id": playerObjID}).Decode(&player)
+	err = collPlayers.FindOne(ctx, bson.M{"_id": playerObjID}).Decode(&player)
 	if err != nil {
 		return err
 	}
@@ -570,7 +1224,7 @@ func AddGoalscorerToFixtureOnly(fixtureID, playerID string) error {
 	}
 
 	_, err = collFixtures.UpdateOne(
-		context.TODO(),
+		ctx,
 		bson.M{"_id": fixtureObjID},
 		update,
 	)
@@ -578,10 +1232,14 @@ func AddGoalscorerToFixtureOnly(fixtureID, playerID string) error {
 }
 
 // 2. Count player goals
-func CountPlayerGoals(playerObjID bson.ObjectID) (int64, error) {
-	collFixtures := client.Database(db).Collection(fixtures)
+func CountPlayerGoals(ctx context.Context, playerObjID bson.ObjectID) (int64, error) {
+	collFixtures, err := scoped(ctx, fixtures)
+	if err != nil {
+		return 0, err
+	}
 
 	pipeline := mongo.Pipeline{
+		{{"$match", completedFixture}},
 		{{"$project", bson.D{
 			{"count", bson.D{
 				{"$size", bson.D{
@@ -599,16 +1257,16 @@ func CountPlayerGoals(playerObjID bson.ObjectID) (int64, error) {
 		}}},
 	}
 
-	cursor, err := collFixtures.Aggregate(context.TODO(), pipeline)
+	cursor, err := collFixtures.Aggregate(ctx, pipeline)
 	if err != nil {
 		return 0, err
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var result struct {
 		Total int64 `bson:"total"`
 	}
-	if cursor.Next(context.TODO()) {
+	if cursor.Next(ctx) {
 		if err := cursor.Decode(&result); err != nil {
 			return 0, err
 		}
@@ -618,10 +1276,13 @@ func CountPlayerGoals(playerObjID bson.ObjectID) (int64, error) {
 }
 
 // 3. Update player goals field
-func UpdatePlayerGoalsField(playerObjID bson.ObjectID, count int64) error {
-	collPlayers := client.Database(db).Collection(players)
-	_, err := collPlayers.UpdateOne(
-		context.TODO(),
+func UpdatePlayerGoalsField(ctx context.Context, playerObjID bson.ObjectID, count int64) error {
+	collPlayers, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
+	_, err = collPlayers.UpdateOne(
+		ctx,
 		bson.M{"_id": playerObjID},
 		bson.M{"$set": bson.M{"goals": count}},
 	)
@@ -629,9 +1290,9 @@ func UpdatePlayerGoalsField(playerObjID bson.ObjectID, count int64) error {
 }
 
 // 4. Coordinator
-func AddGoalscorerToFixture(fixtureID, playerID string) (Fixture, error) {
+func AddGoalscorerToFixture(ctx context.Context, fixtureID, playerID string) (Fixture, error) {
 	// Add goalscorer to fixture
-	err := AddGoalscorerToFixtureOnly(fixtureID, playerID)
+	err := AddGoalscorerToFixtureOnly(ctx, fixtureID, playerID)
 	if err != nil {
 		return Fixture{}, err
 	}
@@ -643,25 +1304,31 @@ func AddGoalscorerToFixture(fixtureID, playerID string) (Fixture, error) {
 	}
 
 	// Count goals
-	count, err := CountPlayerGoals(playerObjID)
+	count, err := CountPlayerGoals(ctx, playerObjID)
 	if err != nil {
 		return Fixture{}, err
 	}
 
 	// Update player goals field
-	err = UpdatePlayerGoalsField(playerObjID, count)
+	err = UpdatePlayerGoalsField(ctx, playerObjID, count)
 	if err != nil {
 		return Fixture{}, err
 	}
 
 	// Return the updated fixture (refetch as needed)
-	return GetFixtureByID(fixtureID)
+	return GetFixtureByID(ctx, fixtureID)
 }
 
 // 1. Add assis to fixture
-func AddStatToFixtureOnly(fixtureID, playerID, stat string) error {
-	collFixtures := client.Database(db).Collection(fixtures)
-	collPlayers := client.Database(db).Collection(players)
+func AddStatToFixtureOnly(ctx context.Context, fixtureID, playerID, stat string) error {
+	collFixtures, err := scoped(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+	collPlayers, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
 
 	fixtureObjID, err := bson.ObjectIDFromHex(fixtureID)
 	if err != nil {
@@ -674,7 +1341,7 @@ func AddStatToFixtureOnly(fixtureID, playerID, stat string) error {
 
 	// Get player name
 	var player Player
-	err = collPlayers.FindOne(context.TODO(), bson.M{"_id": playerObjID}).Decode(&player)
+	err = collPlayers.FindOne(ctx, bson.M{"_id": playerObjID}).Decode(&player)
 	if err != nil {
 		return err
 	}
@@ -687,7 +1354,7 @@ func AddStatToFixtureOnly(fixtureID, playerID, stat string) error {
 	}
 
 	_, err = collFixtures.UpdateOne(
-		context.TODO(),
+		ctx,
 		bson.M{"_id": fixtureObjID},
 		update,
 	)
@@ -695,10 +1362,14 @@ func AddStatToFixtureOnly(fixtureID, playerID, stat string) error {
 }
 
 // 2. Count player assists
-func CountPlayerStat(playerObjID bson.ObjectID, stat string) (int64, error) {
-	collFixtures := client.Database(db).Collection(fixtures)
+func CountPlayerStat(ctx context.Context, playerObjID bson.ObjectID, stat string) (int64, error) {
+	collFixtures, err := scoped(ctx, fixtures)
+	if err != nil {
+		return 0, err
+	}
 
 	pipeline := mongo.Pipeline{
+		{{"$match", completedFixture}},
 		{{"$project", bson.D{
 			{"count", bson.D{
 				{"$size", bson.D{
@@ -716,16 +1387,16 @@ func CountPlayerStat(playerObjID bson.ObjectID, stat string) (int64, error) {
 		}}},
 	}
 
-	cursor, err := collFixtures.Aggregate(context.TODO(), pipeline)
+	cursor, err := collFixtures.Aggregate(ctx, pipeline)
 	if err != nil {
 		return 0, err
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var result struct {
 		Total int64 `bson:"total"`
 	}
-	if cursor.Next(context.TODO()) {
+	if cursor.Next(ctx) {
 		if err := cursor.Decode(&result); err != nil {
 			return 0, err
 		}
@@ -735,10 +1406,13 @@ func CountPlayerStat(playerObjID bson.ObjectID, stat string) (int64, error) {
 }
 
 // 3. Update player stat field
-func UpdatePlayerStatField(playerObjID bson.ObjectID, count int64, stat string) error {
-	collPlayers := client.Database(db).Collection(players)
-	_, err := collPlayers.UpdateOne(
-		context.TODO(),
+func UpdatePlayerStatField(ctx context.Context, playerObjID bson.ObjectID, count int64, stat string) error {
+	collPlayers, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
+	_, err = collPlayers.UpdateOne(
+		ctx,
 		bson.M{"_id": playerObjID},
 		bson.M{"$set": bson.M{stat: count}},
 	)
@@ -746,9 +1420,9 @@ func UpdatePlayerStatField(playerObjID bson.ObjectID, count int64, stat string)
 
 }
 
-func AddStatToFixture(fixtureID, playerID, stat string) (Fixture, error) {
+func AddStatToFixture(ctx context.Context, fixtureID, playerID, stat string) (Fixture, error) {
 	// Add goalscorer to fixture
-	err := AddStatToFixtureOnly(fixtureID, playerID, stat)
+	err := AddStatToFixtureOnly(ctx, fixtureID, playerID, stat)
 	if err != nil {
 		return Fixture{}, err
 	}
@@ -760,17 +1434,17 @@ func AddStatToFixture(fixtureID, playerID, stat string) (Fixture, error) {
 	}
 
 	// Count stat
-	count, err := CountPlayerStat(playerObjID, stat)
+	count, err := CountPlayerStat(ctx, playerObjID, stat)
 	if err != nil {
 		return Fixture{}, err
 	}
 
 	// Update player stat field
-	err = UpdatePlayerStatField(playerObjID, count, stat)
+	err = UpdatePlayerStatField(ctx, playerObjID, count, playerStatFields[stat])
 	if err != nil {
 		return Fixture{}, err
 	}
 
 	// Return the updated fixture (refetch as needed)
-	return GetFixtureByID(fixtureID)
+	return GetFixtureByID(ctx, fixtureID)
 }
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestDemoteAdmin(t *testing.T) {
+	ctx := testClub(t)
+	first := testUser(t, ctx, RoleAdmin)
+	second := testUser(t, ctx, RoleAdmin)
+
+	if err := DemoteAdmin(ctx, first.ID, RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	if err := DemoteAdmin(ctx, second.ID, RoleCoach); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("demoting the last admin: %v, want ErrLastAdmin", err)
+	}
+	if user, err := GetClubUserByID(ctx, second.ID.Hex()); err != nil || user.GetRole() != RoleAdmin {
+		t.Fatalf("last admin = %q, %v, want still admin", user.GetRole(), err)
+	}
+
+	viewer := testUser(t, ctx, RoleViewer)
+	if err := DemoteAdmin(ctx, viewer.ID, RoleCoach); err == nil {
+		t.Fatal("demoted a user who isn't an admin")
+	}
+}
+
+// Two admins demoting each other at once mustn't leave the club with none.
+func TestDemoteAdminConcurrently(t *testing.T) {
+	ctx := testClub(t)
+
+	for i := 0; i < 20; i++ {
+		admins := []User{testUser(t, ctx, RoleAdmin), testUser(t, ctx, RoleAdmin)}
+
+		var wg sync.WaitGroup
+		for _, admin := range admins {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := DemoteAdmin(ctx, admin.ID, RoleViewer); err != nil && !errors.Is(err, ErrLastAdmin) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		count, err := CountClubAdmins(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Both may be refused when each sees the other's demotion
+		if count < 1 {
+			t.Fatalf("round %d: no admins left", i)
+		}
+
+		// Start the next round with no admins
+		for _, admin := range admins {
+			if err := SetUserRole(ctx, admin.ID.Hex(), RoleViewer); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrEventNotFound is returned when the fixture's timeline has no event
+// with the ID.
+var ErrEventNotFound = errors.New("event not found")
+
+// Events are kept in match order, stoppage time after the minute it follows
+var eventOrder = bson.D{{"minute", 1}, {"added_time", 1}}
+
+// AddFixtureEvent adds an event to the fixture's timeline. Once a fixture
+// has a timeline its score and scorer lists are rebuilt from the events, so
+// a fixture whose scorers or result were entered without one can't start a
+// timeline.
+func AddFixtureEvent(ctx context.Context, fixtureID string, event MatchEvent) (MatchEvent, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+
+	fixture, err := GetFixtureByID(ctx, fixtureID)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+	if len(fixture.Events) == 0 && (len(fixture.GoalScorers) > 0 || len(fixture.AssistScorers) > 0) {
+		return MatchEvent{}, fmt.Errorf("this fixture's scorers were entered without a timeline")
+	}
+	if len(fixture.Events) == 0 && fixture.Status == FixtureCompleted && enteredScore(fixture) {
+		return MatchEvent{}, fmt.Errorf("this fixture's result was entered without a timeline")
+	}
+
+	event.ID = bson.NewObjectID()
+	if err := resolveEventNames(ctx, &event); err != nil {
+		return MatchEvent{}, err
+	}
+
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"_id": fixture.ID},
+		bson.M{"$push": bson.M{"events": bson.M{"$each": []MatchEvent{event}, "$sort": eventOrder}}},
+	)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+
+	return event, syncFixtureScorers(ctx, fixture)
+}
+
+// UpdateFixtureEvent replaces an event in the fixture's timeline.
+func UpdateFixtureEvent(ctx context.Context, fixtureID, eventID string, event MatchEvent) (MatchEvent, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+
+	fixture, err := GetFixtureByID(ctx, fixtureID)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+	event.ID, err = bson.ObjectIDFromHex(eventID)
+	if err != nil {
+		return MatchEvent{}, ErrEventNotFound
+	}
+	if err := resolveEventNames(ctx, &event); err != nil {
+		return MatchEvent{}, err
+	}
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": fixture.ID, "events._id": event.ID},
+		bson.M{"$set": bson.M{"events.$": event}},
+	)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+	if result.MatchedCount == 0 {
+		return MatchEvent{}, ErrEventNotFound
+	}
+
+	// The minute may have changed, so put the timeline back in order
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"_id": fixture.ID},
+		bson.M{"$push": bson.M{"events": bson.M{"$each": []MatchEvent{}, "$sort": eventOrder}}},
+	)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+
+	return event, syncFixtureScorers(ctx, fixture)
+}
+
+// DeleteFixtureEvent removes an event from the fixture's timeline.
+func DeleteFixtureEvent(ctx context.Context, fixtureID, eventID string) error {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+
+	fixture, err := GetFixtureByID(ctx, fixtureID)
+	if err != nil {
+		return err
+	}
+	eventObjID, err := bson.ObjectIDFromHex(eventID)
+	if err != nil {
+		return ErrEventNotFound
+	}
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": fixture.ID},
+		bson.M{"$pull": bson.M{"events": bson.M{"_id": eventObjID}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return ErrEventNotFound
+	}
+
+	return syncFixtureScorers(ctx, fixture)
+}
+
+// resolveEventNames copies the names of the players involved onto the event.
+func resolveEventNames(ctx context.Context, event *MatchEvent) error {
+	names := []struct {
+		id   bson.ObjectID
+		name *string
+	}{
+		{event.PlayerID, &event.PlayerName},
+		{event.AssistID, &event.AssistName},
+		{event.SubstituteID, &event.SubstituteName},
+	}
+	for _, n := range names {
+		*n.name = ""
+		if n.id.IsZero() {
+			continue
+		}
+		player, err := GetPlayerByID(ctx, n.id.Hex())
+		if err != nil {
+			return fmt.Errorf("player not found")
+		}
+		*n.name = player.Name
+	}
+	return nil
+}
+
+// enteredScore reports whether the fixture has a result an empty timeline
+// would overwrite. A 0-0 is what the timeline starts from, and is also what
+// is left when every event of a timeline is deleted.
+func enteredScore(fixture Fixture) bool {
+	return (fixture.HomeScore != nil && *fixture.HomeScore != 0) ||
+		(fixture.AwayScore != nil && *fixture.AwayScore != 0)
+}
+
+// TimelineScore counts the goals in a timeline. Own goals count for the
+// other side.
+func TimelineScore(events []MatchEvent) (home, away int) {
+	for _, event := range events {
+		side := event.Side
+		switch event.Type {
+		case EventGoal, EventPenaltyScored:
+		case EventOwnGoal:
+			if side == SideHome {
+				side = SideAway
+			} else {
+				side = SideHome
+			}
+		default:
+			continue
+		}
+		if side == SideHome {
+			home++
+		} else {
+			away++
+		}
+	}
+	return home, away
+}
+
+// syncFixtureScorers rebuilds the fixture's score and scorer lists from its
+// timeline and recounts the totals of every player who was or is on them.
+func syncFixtureScorers(ctx context.Context, before Fixture) error {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+
+	fixture, err := GetFixtureByID(ctx, before.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	goalScorers, goalScorersNames := []bson.ObjectID{}, []string{}
+	assistScorers, assistScorersNames := []bson.ObjectID{}, []string{}
+	for _, event := range fixture.Events {
+		scorer, ok := event.ScoredBy()
+		if !ok {
+			continue
+		}
+		goalScorers = append(goalScorers, scorer)
+		goalScorersNames = append(goalScorersNames, event.PlayerName)
+		if !event.AssistID.IsZero() {
+			assistScorers = append(assistScorers, event.AssistID)
+			assistScorersNames = append(assistScorersNames, event.AssistName)
+		}
+	}
+
+	homeScore, awayScore := TimelineScore(fixture.Events)
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": fixture.ID}, bson.M{"$set": bson.M{
+		"home_score":           homeScore,
+		"away_score":           awayScore,
+		"goal_scorers":         goalScorers,
+		"goal_scorers_names":   goalScorersNames,
+		"assist_scorers":       assistScorers,
+		"assist_scorers_names": assistScorersNames,
+	}})
+	if err != nil {
+		return err
+	}
+
+	// Live matches don't count towards totals until they are completed
+	if fixture.Status != FixtureCompleted {
+		return nil
+	}
+	ids := append(append([]bson.ObjectID{}, before.GoalScorers...), before.AssistScorers...)
+	ids = append(append(ids, goalScorers...), assistScorers...)
+	return RecountPlayerStats(ctx, ids...)
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestTimelineScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []MatchEvent
+		home, away int
+	}{
+		{"no events", nil, 0, 0},
+		{"goals", []MatchEvent{
+			{Type: EventGoal, Side: SideHome},
+			{Type: EventGoal, Side: SideAway},
+			{Type: EventGoal, Side: SideHome},
+		}, 2, 1},
+		{"penalties", []MatchEvent{
+			{Type: EventPenaltyScored, Side: SideAway},
+			{Type: EventPenaltyMissed, Side: SideHome},
+		}, 0, 1},
+		{"own goals count for the other side", []MatchEvent{
+			{Type: EventOwnGoal, Side: SideHome},
+			{Type: EventOwnGoal, Side: SideAway},
+			{Type: EventOwnGoal, Side: SideAway},
+		}, 2, 1},
+		{"other events don't score", []MatchEvent{
+			{Type: EventYellowCard, Side: SideHome},
+			{Type: EventSubstitution, Side: SideAway},
+		}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, away := TimelineScore(tt.events)
+			if home != tt.home || away != tt.away {
+				t.Errorf("score = %d-%d, want %d-%d", home, away, tt.home, tt.away)
+			}
+		})
+	}
+}
+
+// testFixture adds a fixture between the team and a new opponent.
+func testFixture(t *testing.T, ctx context.Context, team Team, homeScore, awayScore *int) Fixture {
+	t.Helper()
+	opponent, err := AddOpponent(ctx, "Blues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := AddFixture(ctx, time.Now(), team.ID, opponent.ID, homeScore, awayScore, "", "", "", bson.ObjectID{}, bson.ObjectID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fixture
+}
+
+func wantScore(t *testing.T, ctx context.Context, id bson.ObjectID, home, away int) Fixture {
+	t.Helper()
+	fixture, err := GetFixtureByID(ctx, id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture.HomeScore == nil || fixture.AwayScore == nil || *fixture.HomeScore != home || *fixture.AwayScore != away {
+		t.Fatalf("score = %v-%v, want %d-%d", fixture.HomeScore, fixture.AwayScore, home, away)
+	}
+	return fixture
+}
+
+// A result entered by hand isn't thrown away by the first event of a
+// timeline.
+func TestAddEventKeepsEnteredResult(t *testing.T) {
+	ctx := testClub(t)
+	team, player := testPlayer(t, ctx)
+	three, one := 3, 1
+	fixture := testFixture(t, ctx, team, &three, &one)
+
+	card := MatchEvent{Type: EventYellowCard, Side: SideHome, Minute: 30, PlayerID: player.ID}
+	if _, err := AddFixtureEvent(ctx, fixture.ID.Hex(), card); err == nil {
+		t.Fatal("added an event to a result entered without a timeline")
+	}
+	if fixture := wantScore(t, ctx, fixture.ID, 3, 1); len(fixture.Events) != 0 {
+		t.Fatalf("events = %v, want none", fixture.Events)
+	}
+}
+
+// A 0-0 is what an empty timeline says, so it can still start one.
+func TestAddEventToGoallessResult(t *testing.T) {
+	ctx := testClub(t)
+	team, player := testPlayer(t, ctx)
+	zero := 0
+	fixture := testFixture(t, ctx, team, &zero, &zero)
+
+	card := MatchEvent{Type: EventYellowCard, Side: SideHome, Minute: 30, PlayerID: player.ID}
+	if _, err := AddFixtureEvent(ctx, fixture.ID.Hex(), card); err != nil {
+		t.Fatal(err)
+	}
+	wantScore(t, ctx, fixture.ID, 0, 0)
+}
+
+func TestFixtureEventsSyncScore(t *testing.T) {
+	ctx := testClub(t)
+	team, player := testPlayer(t, ctx)
+	fixture := testFixture(t, ctx, team, nil, nil)
+	id := fixture.ID.Hex()
+	if _, err := SetFixtureStatus(ctx, id, FixtureLive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	goal, err := AddFixtureEvent(ctx, id, MatchEvent{Type: EventGoal, Side: SideHome, Minute: 10, PlayerID: player.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture := wantScore(t, ctx, fixture.ID, 1, 0); len(fixture.GoalScorers) != 1 || fixture.GoalScorers[0] != player.ID {
+		t.Fatalf("goal scorers = %v, want the player", fixture.GoalScorers)
+	}
+
+	reply, err := AddFixtureEvent(ctx, id, MatchEvent{Type: EventGoal, Side: SideAway, Minute: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantScore(t, ctx, fixture.ID, 1, 1)
+
+	// Goals count towards the player's total once the fixture is completed
+	if _, err := SetFixtureStatus(ctx, id, FixtureCompleted, bson.M{"home_score": 1, "away_score": 1}); err != nil {
+		t.Fatal(err)
+	}
+	wantGoals(t, ctx, player.ID, 1)
+
+	goal.Type = EventOwnGoal
+	if _, err := UpdateFixtureEvent(ctx, id, goal.ID.Hex(), goal); err != nil {
+		t.Fatal(err)
+	}
+	if fixture := wantScore(t, ctx, fixture.ID, 0, 2); len(fixture.GoalScorers) != 0 {
+		t.Fatalf("goal scorers = %v, want none for an own goal", fixture.GoalScorers)
+	}
+	wantGoals(t, ctx, player.ID, 0)
+
+	if err := DeleteFixtureEvent(ctx, id, reply.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	wantScore(t, ctx, fixture.ID, 0, 1)
+
+	if err := DeleteFixtureEvent(ctx, id, reply.ID.Hex()); !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("deleting a deleted event: %v, want ErrEventNotFound", err)
+	}
+}
+
+func wantGoals(t *testing.T, ctx context.Context, playerID bson.ObjectID, goals int) {
+	t.Helper()
+	player, err := GetPlayerByID(ctx, playerID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.Goals != goals {
+		t.Fatalf("player goals = %d, want %d", player.Goals, goals)
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Only completed matches count towards stats and leaderboards
+var completedFixture = bson.D{{"status", FixtureCompleted}}
+
+// Player counter each fixture stat list is totalled into
+var playerStatFields = map[string]string{
+	"goal_scorers":   "goals",
+	"assist_scorers": "assists",
+}
+
+// MigrateFixtureStatus marks fixtures from before statuses existed as
+// completed, as they could only be entered with a result.
+func MigrateFixtureStatus() {
+	result, err := client.Database(db).Collection(fixtures).UpdateMany(
+		context.TODO(),
+		bson.M{"status": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"status": FixtureCompleted}},
+	)
+	if err != nil {
+		log.Printf("Warning: could not migrate fixture statuses: %v", err)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Marked %d fixtures as completed", result.ModifiedCount)
+	}
+}
+
+// SetFixtureStatus moves the fixture to status, setting any extra fields
+// with it. The update only applies if nobody changed the status meanwhile.
+func SetFixtureStatus(ctx context.Context, id, status string, set bson.M) (Fixture, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return Fixture{}, err
+	}
+
+	fixture, err := GetFixtureByID(ctx, id)
+	if err != nil {
+		return Fixture{}, err
+	}
+	if !CanTransition(fixture.Status, status) {
+		return Fixture{}, fmt.Errorf("a %s fixture can't be marked %s", fixture.Status, status)
+	}
+
+	update := bson.M{"status": status}
+	for field, value := range set {
+		update[field] = value
+	}
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": fixture.ID, "status": fixture.Status},
+		bson.M{"$set": update},
+	)
+	if err != nil {
+		return Fixture{}, err
+	}
+	if result.MatchedCount == 0 {
+		return Fixture{}, fmt.Errorf("fixture was changed by someone else, please try again")
+	}
+
+	// Stats from a match only count once it is completed
+	if status == FixtureCompleted {
+		fixture, err = GetFixtureByID(ctx, id)
+		if err != nil {
+			return Fixture{}, err
+		}
+		ids := append(append([]bson.ObjectID{}, fixture.GoalScorers...), fixture.AssistScorers...)
+		if !fixture.ManOfTheMatch.IsZero() {
+			ids = append(ids, fixture.ManOfTheMatch)
+		}
+		if err := RecountPlayerStats(ctx, ids...); err != nil {
+			return Fixture{}, err
+		}
+	}
+
+	return GetFixtureByID(ctx, id)
+}
+
+// SetManOfTheMatch picks the fixture's man of the match, or clears it when
+// playerID is zero. Completed fixtures are recounted for the old and new pick.
+func SetManOfTheMatch(ctx context.Context, id string, playerID bson.ObjectID) (Fixture, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return Fixture{}, err
+	}
+
+	fixture, err := GetFixtureByID(ctx, id)
+	if err != nil {
+		return Fixture{}, err
+	}
+	if fixture.Status != FixtureLive && fixture.Status != FixtureCompleted {
+		return Fixture{}, fmt.Errorf("man of the match can only be picked once the fixture has kicked off")
+	}
+
+	update := bson.M{"$set": bson.M{"man_of_the_match": playerID}}
+	if playerID.IsZero() {
+		update = bson.M{"$unset": bson.M{"man_of_the_match": ""}}
+	}
+	if _, err := coll.UpdateOne(ctx, bson.M{"_id": fixture.ID}, update); err != nil {
+		return Fixture{}, err
+	}
+
+	if fixture.Status == FixtureCompleted {
+		var ids []bson.ObjectID
+		for _, player := range []bson.ObjectID{fixture.ManOfTheMatch, playerID} {
+			if !player.IsZero() {
+				ids = append(ids, player)
+			}
+		}
+		if err := RecountPlayerStats(ctx, ids...); err != nil {
+			return Fixture{}, err
+		}
+	}
+
+	return GetFixtureByID(ctx, id)
+}
+
+// RecountPlayerStats totals the players' goals, assists and man of the
+// match awards from completed fixtures. The fixtures are the only record of
+// them, so the totals are replaced rather than adjusted.
+func RecountPlayerStats(ctx context.Context, playerIDs ...bson.ObjectID) error {
+	collFixtures, err := scoped(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+	collPlayers, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
+
+	seen := map[bson.ObjectID]bool{}
+	for _, id := range playerIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		counts := bson.M{}
+		for stat, field := range playerStatFields {
+			count, err := CountPlayerStat(ctx, id, stat)
+			if err != nil {
+				return err
+			}
+			counts[field] = count
+		}
+
+		motm, err := collFixtures.CountDocuments(ctx, append(bson.D{{"man_of_the_match", id}}, completedFixture...))
+		if err != nil {
+			return err
+		}
+		counts["man_of_the_match"] = motm
+
+		if _, err := collPlayers.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": counts}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
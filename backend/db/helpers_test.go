@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"fctracker/passwords"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var connectOnce sync.Once
+
+// requireMongo connects to the server in MONGODB_TEST_URI and skips the test
+// when it isn't set. Point it at a throwaway server, the tests write to the
+// fctracker database.
+func requireMongo(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	connectOnce.Do(func() {
+		os.Setenv("MONGODB_URI", uri)
+		Connect()
+
+		// Real hashing costs would make every test user take a while
+		hasher, err := passwords.NewHasher(passwords.Params{
+			Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+		})
+		if err != nil {
+			panic(err)
+		}
+		SetPasswordHasher(hasher)
+	})
+}
+
+// testClub returns a context for a new club whose documents are deleted
+// when the test ends.
+func testClub(t *testing.T) context.Context {
+	t.Helper()
+	requireMongo(t)
+
+	tenantID := bson.NewObjectID()
+	t.Cleanup(func() {
+		ctx := context.Background()
+		names, err := client.Database(db).ListCollectionNames(ctx, bson.M{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, name := range names {
+			client.Database(db).Collection(name).DeleteMany(ctx, bson.M{"tenant_id": tenantID})
+		}
+	})
+	return WithTenant(context.Background(), tenantID)
+}
+
+// testUser adds a user with the role to the club in ctx.
+func testUser(t *testing.T, ctx context.Context, role string) User {
+	t.Helper()
+	tenantID, _ := TenantFromContext(ctx)
+	email := "test-" + bson.NewObjectID().Hex() + "@example.com"
+	user, err := CreateUser(ctx, email, "kettle-violin-42", "Test User", role, tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// testPlayer adds a team with one player to the club.
+func testPlayer(t *testing.T, ctx context.Context) (Team, Player) {
+	t.Helper()
+	team, err := AddTeam(ctx, "Reds", "Coach", 1990)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddPlayer(ctx, "Striker", "Forward", "", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), team.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	// AddPlayer doesn't return the new player's ID
+	players, err := GetActivePlayers(ctx)
+	if err != nil || len(players) != 1 {
+		t.Fatalf("players = %v, %v", players, err)
+	}
+	return team, players[0]
+}
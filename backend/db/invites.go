@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	invites = "invites"
+)
+
+func EnsureInviteIndexes() {
+	coll := client.Database(db).Collection(invites)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"code_hash", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{"team_id", 1}},
+		},
+		{
+			// Keep expired invites around for a while so coaches can see them
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32((30 * 24 * time.Hour).Seconds())),
+		},
+	}
+	_, err := coll.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		log.Printf("Warning: could not create indexes on invites: %v", err)
+	}
+}
+
+func CreateInvite(ctx context.Context, teamID bson.ObjectID, role, codeHash string, maxUses int, createdBy bson.ObjectID, expiresAt time.Time) (Invite, error) {
+	coll, err := scoped(ctx, invites)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	invite := Invite{
+		TeamID:    teamID,
+		Role:      role,
+		CodeHash:  codeHash,
+		MaxUses:   maxUses,
+		CreatedBy: createdBy,
+		ExpiresAt: expiresAt,
+		Created:   time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, invite)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	invite.ID = result.InsertedID.(bson.ObjectID)
+	return invite, nil
+}
+
+// GetTeamInvites lists the team's invites, newest first.
+func GetTeamInvites(ctx context.Context, teamID bson.ObjectID) ([]Invite, error) {
+	coll, err := scoped(ctx, invites)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Find(ctx, bson.M{"team_id": teamID}, options.Find().SetSort(bson.D{{"_id", -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []Invite{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func RevokeInvite(ctx context.Context, teamID bson.ObjectID, id string) error {
+	coll, err := scoped(ctx, invites)
+	if err != nil {
+		return err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": objID, "team_id": teamID}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("invite not found")
+	}
+	return nil
+}
+
+// usableInvite matches an invite that can still be redeemed.
+func usableInvite(codeHash string) bson.M {
+	return bson.M{
+		"code_hash":  codeHash,
+		"revoked":    false,
+		"expires_at": bson.M{"$gt": time.Now()},
+		"$expr":      bson.M{"$lt": bson.A{"$uses", "$max_uses"}},
+	}
+}
+
+// GetInviteByCode finds a usable invite with the team name resolved. Like
+// the other token lookups it works across clubs, since the code is all a
+// visitor has.
+func GetInviteByCode(ctx context.Context, codeHash string) (Invite, error) {
+	coll := client.Database(db).Collection(invites)
+
+	pipeline := mongo.Pipeline{
+		{{"$match", usableInvite(codeHash)}},
+		{{"$lookup", bson.D{
+			{"from", "teams"},
+			{"localField", "team_id"},
+			{"foreignField", "_id"},
+			// Only the invite's own club's team
+			{"let", bson.D{{"tenant", "$tenant_id"}}},
+			{"pipeline", bson.A{
+				bson.D{{"$match", bson.D{{"$expr", bson.D{{"$eq", bson.A{"$tenant_id", "$$tenant"}}}}}}},
+			}},
+			{"as", "teamDetails"},
+		}}},
+		{{"$addFields", bson.D{
+			{"team_name", bson.D{
+				{"$arrayElemAt", bson.A{"$teamDetails.name", 0}},
+			}},
+		}}},
+		{{"$project", bson.D{
+			{"teamDetails", 0},
+		}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return Invite{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []Invite
+	if err := cursor.All(ctx, &result); err != nil {
+		return Invite{}, err
+	}
+	if len(result) == 0 {
+		return Invite{}, fmt.Errorf("invite not found")
+	}
+	return result[0], nil
+}
+
+// RedeemInvite uses up one of the invite's uses. Call ReleaseInvite if the
+// registration it was for fails.
+func RedeemInvite(ctx context.Context, codeHash string) (Invite, error) {
+	coll := client.Database(db).Collection(invites)
+
+	var invite Invite
+	err := coll.FindOneAndUpdate(ctx,
+		usableInvite(codeHash),
+		bson.M{"$inc": bson.M{"uses": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invite)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Invite{}, fmt.Errorf("invite not found")
+		}
+		return Invite{}, err
+	}
+	return invite, nil
+}
+
+func ReleaseInvite(ctx context.Context, id bson.ObjectID) error {
+	coll := client.Database(db).Collection(invites)
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id, "uses": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"uses": -1}})
+	return err
+}
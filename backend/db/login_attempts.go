@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fctracker/lockout"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	loginAttempts = "login_attempts"
+
+	// Records nobody has touched for this long are dropped by Mongo
+	loginAttemptRetention = 7 * 24 * time.Hour
+)
+
+func EnsureLoginAttemptIndexes() {
+	coll := client.Database(db).Collection(loginAttempts)
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{"last_failure", 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(loginAttemptRetention.Seconds())),
+	}
+	_, err := coll.Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create index on login_attempts: %v", err)
+	}
+}
+
+// LoginAttemptStore keeps failed login counts in Mongo so lockouts survive
+// restarts. It implements lockout.Store.
+type LoginAttemptStore struct{}
+
+func (LoginAttemptStore) Get(ctx context.Context, key string) (lockout.Record, error) {
+	coll := client.Database(db).Collection(loginAttempts)
+
+	var record lockout.Record
+	err := coll.FindOne(ctx, bson.M{"_id": key}).Decode(&record)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return lockout.Record{}, nil
+		}
+		return lockout.Record{}, err
+	}
+	return record, nil
+}
+
+func (LoginAttemptStore) AddFailure(ctx context.Context, key string, at time.Time) (lockout.Record, error) {
+	coll := client.Database(db).Collection(loginAttempts)
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var record lockout.Record
+	err := coll.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"last_failure": at},
+		},
+		opts,
+	).Decode(&record)
+	return record, err
+}
+
+func (LoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	coll := client.Database(db).Collection(loginAttempts)
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"locked_until": until}})
+	return err
+}
+
+func (LoginAttemptStore) Reset(ctx context.Context, key string) error {
+	coll := client.Database(db).Collection(loginAttempts)
+	_, err := coll.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	memberships = "memberships"
+)
+
+func EnsureMembershipIndexes() {
+	coll := client.Database(db).Collection(memberships)
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{"user_id", 1}, {"team_id", 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := coll.Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on memberships: %v", err)
+	}
+}
+
+func AddMembership(ctx context.Context, userID, teamID bson.ObjectID, role, status string, invitedBy bson.ObjectID) (Membership, error) {
+	coll, err := scoped(ctx, memberships)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	membership := Membership{
+		UserID:    userID,
+		TeamID:    teamID,
+		Role:      role,
+		Status:    status,
+		InvitedBy: invitedBy,
+		Created:   time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, membership)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Membership{}, fmt.Errorf("user is already a member of this team")
+		}
+		return Membership{}, err
+	}
+
+	membership.ID = result.InsertedID.(bson.ObjectID)
+	return membership, nil
+}
+
+func GetMembership(ctx context.Context, userID, teamID string) (Membership, error) {
+	coll, err := scoped(ctx, memberships)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	userObjID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return Membership{}, err
+	}
+	teamObjID, err := bson.ObjectIDFromHex(teamID)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	var membership Membership
+	err = coll.FindOne(ctx, bson.M{"user_id": userObjID, "team_id": teamObjID}).Decode(&membership)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Membership{}, fmt.Errorf("membership not found")
+		}
+		return Membership{}, err
+	}
+	return membership, nil
+}
+
+// GetUserMemberships lists a user's memberships with the team name resolved.
+// An empty status returns memberships of every status.
+func GetUserMemberships(ctx context.Context, userID, status string) ([]Membership, error) {
+	coll, err := scoped(ctx, memberships)
+	if err != nil {
+		return nil, err
+	}
+
+	objID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.D{{"user_id", objID}}
+	if status != "" {
+		match = append(match, bson.E{"status", status})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$lookup", bson.D{
+			{"from", "teams"},
+			{"localField", "team_id"},
+			{"foreignField", "_id"},
+			{"as", "teamDetails"},
+		}}},
+		{{"$addFields", bson.D{
+			{"team_name", bson.D{
+				{"$arrayElemAt", bson.A{"$teamDetails.name", 0}},
+			}},
+		}}},
+		{{"$project", bson.D{
+			{"teamDetails", 0},
+		}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Membership
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetTeamMembers lists a team's memberships with the user's name and email.
+func GetTeamMembers(ctx context.Context, teamID string) ([]Membership, error) {
+	coll, err := scoped(ctx, memberships)
+	if err != nil {
+		return nil, err
+	}
+
+	objID, err := bson.ObjectIDFromHex(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"team_id", objID}}}},
+		{{"$lookup", bson.D{
+			{"from", "users"},
+			{"localField", "user_id"},
+			{"foreignField", "_id"},
+			{"as", "userDetails"},
+		}}},
+		{{"$addFields", bson.D{
+			{"user_name", bson.D{
+				{"$arrayElemAt", bson.A{"$userDetails.name", 0}},
+			}},
+			{"user_email", bson.D{
+				{"$arrayElemAt", bson.A{"$userDetails.email", 0}},
+			}},
+		}}},
+		{{"$project", bson.D{
+			{"userDetails", 0},
+		}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Membership
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func AcceptMembership(ctx context.Context, userID, teamID string) (Membership, error) {
+	coll, err := scoped(ctx, memberships)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	userObjID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return Membership{}, err
+	}
+	teamObjID, err := bson.ObjectIDFromHex(teamID)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var membership Membership
+	err = coll.FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": userObjID, "team_id": teamObjID, "status": MembershipInvited},
+		bson.M{"$set": bson.M{"status": MembershipActive}},
+		opts,
+	).Decode(&membership)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Membership{}, fmt.Errorf("invite not found")
+		}
+		return Membership{}, err
+	}
+	return membership, nil
+}
+
+func RemoveMembership(ctx context.Context, userID, teamID string) error {
+	coll, err := scoped(ctx, memberships)
+	if err != nil {
+		return err
+	}
+
+	userObjID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	teamObjID, err := bson.ObjectIDFromHex(teamID)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.DeleteOne(ctx, bson.M{"user_id": userObjID, "team_id": teamObjID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("membership not found")
+	}
+	return nil
+}
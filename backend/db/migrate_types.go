@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Layouts dates were typed in before they were validated. Slashed dates are
+// day first, as entered by the club.
+var legacyDateLayouts = []string{
+	time.RFC3339,
+	format,
+	"2006-01-02",
+	"2006/01/02",
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+	"02.01.2006",
+	"02/01/06",
+}
+
+func parseLegacyDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range legacyDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseLegacyInt(value string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	return n, err == nil && n >= 0
+}
+
+// MigrateTypedFields converts fixture dates and scores, team founding years
+// and player ages stored as free-form strings. Values that can't be read are
+// removed and logged, so they can be entered again.
+func MigrateTypedFields() {
+	migrateDocuments(fixtures, bson.M{"$or": bson.A{
+		bson.M{"date": bson.M{"$type": "string"}},
+		bson.M{"home_score": bson.M{"$type": "string"}},
+		bson.M{"away_score": bson.M{"$type": "string"}},
+	}}, func(doc bson.M, set, unset bson.M) {
+		if value, ok := doc["date"].(string); ok {
+			if date, ok := parseLegacyDate(value); ok {
+				set["date"] = date
+			} else {
+				log.Printf("Warning: fixture %v has unreadable date %q", doc["_id"], value)
+				unset["date"] = ""
+			}
+		}
+		for _, field := range []string{"home_score", "away_score"} {
+			value, ok := doc[field].(string)
+			if !ok {
+				continue
+			}
+			if score, ok := parseLegacyInt(value); ok {
+				set[field] = score
+			} else {
+				if value != "" {
+					log.Printf("Warning: fixture %v has unreadable %s %q", doc["_id"], field, value)
+				}
+				unset[field] = ""
+			}
+		}
+	})
+
+	migrateDocuments(teams, bson.M{"founded": bson.M{"$type": "string"}}, func(doc bson.M, set, unset bson.M) {
+		value := doc["founded"].(string)
+		if year, ok := parseLegacyInt(value); ok && year > 0 {
+			set["founded"] = year
+		} else {
+			if value != "" {
+				log.Printf("Warning: team %v has unreadable founded year %q", doc["_id"], value)
+			}
+			unset["founded"] = ""
+		}
+	})
+
+	// Only an age was recorded, so the date of birth is an estimate that
+	// gives the same age today. Players and coaches can correct it.
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	migrateDocuments(players, bson.M{"age": bson.M{"$exists": true}}, func(doc bson.M, set, unset bson.M) {
+		unset["age"] = ""
+		if _, ok := doc["dob"]; ok {
+			return
+		}
+		value, _ := doc["age"].(string)
+		if age, ok := parseLegacyInt(value); ok && age > 0 && age < 100 {
+			set["dob"] = now.AddDate(-age, 0, 0)
+		} else if value != "" {
+			log.Printf("Warning: player %v has unreadable age %q", doc["_id"], value)
+		}
+	})
+}
+
+// migrateDocuments rewrites every document in the collection matching
+// filter with the fields convert sets and unsets.
+func migrateDocuments(name string, filter bson.M, convert func(doc, set, unset bson.M)) {
+	ctx := context.TODO()
+	coll := client.Database(db).Collection(name)
+
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		log.Printf("Warning: could not migrate %s: %v", name, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("Warning: could not read %s document: %v", name, err)
+			continue
+		}
+
+		set, unset := bson.M{}, bson.M{}
+		convert(doc, set, unset)
+
+		update := bson.M{}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if len(update) == 0 {
+			continue
+		}
+
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, update); err != nil {
+			log.Printf("Warning: could not migrate %s %v: %v", name, doc["_id"], err)
+			continue
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Converted %d %s to typed fields", migrated, name)
+	}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	oidcLogins = "oidc_logins"
+)
+
+func EnsureOIDCLoginIndexes() {
+	coll := client.Database(db).Collection(oidcLogins)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"state_hash", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Abandoned logins are cleaned up by Mongo
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := coll.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		log.Printf("Warning: could not create indexes on oidc_logins: %v", err)
+	}
+}
+
+func CreateOIDCLogin(ctx context.Context, stateHash, nonce, codeVerifier, club string, expiresAt time.Time) error {
+	coll := client.Database(db).Collection(oidcLogins)
+
+	login := OIDCLogin{
+		StateHash:    stateHash,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		Club:         club,
+		ExpiresAt:    expiresAt,
+		Created:      time.Now().Format(format),
+	}
+
+	_, err := coll.InsertOne(ctx, login)
+	return err
+}
+
+// ConsumeOIDCLogin removes and returns the pending login for the state, so
+// each callback can only be used once.
+func ConsumeOIDCLogin(ctx context.Context, stateHash string) (OIDCLogin, error) {
+	coll := client.Database(db).Collection(oidcLogins)
+
+	var login OIDCLogin
+	err := coll.FindOneAndDelete(ctx, bson.M{
+		"state_hash": stateHash,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&login)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return OIDCLogin{}, fmt.Errorf("login is invalid or expired")
+		}
+		return OIDCLogin{}, err
+	}
+	return login, nil
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	opponents = "opponents"
+)
+
+// Opponent names are unique per club, ignoring case
+var opponentCollation = &options.Collation{Locale: "en", Strength: 2}
+
+func EnsureOpponentIndexes() {
+	coll := client.Database(db).Collection(opponents)
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{"tenant_id", 1}, {"name", 1}},
+		Options: options.Index().SetUnique(true).SetCollation(opponentCollation),
+	}
+	_, err := coll.Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on opponents: %v", err)
+	}
+
+	for _, field := range []string{"home_team_id", "away_team_id"} {
+		_, err := client.Database(db).Collection(fixtures).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+			Keys: bson.D{{field, 1}},
+		})
+		if err != nil {
+			log.Printf("Warning: could not create index on fixtures.%s: %v", field, err)
+		}
+	}
+}
+
+func AddOpponent(ctx context.Context, name string) (Opponent, error) {
+	coll, err := scoped(ctx, opponents)
+	if err != nil {
+		return Opponent{}, err
+	}
+
+	opponent := Opponent{
+		Name:    strings.TrimSpace(name),
+		Created: time.Now().Format(format),
+	}
+	if opponent.Name == "" {
+		return Opponent{}, fmt.Errorf("opponent name is required")
+	}
+
+	result, err := coll.InsertOne(ctx, opponent)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Opponent{}, fmt.Errorf("an opponent with this name already exists")
+		}
+		return Opponent{}, err
+	}
+
+	opponent.ID = result.InsertedID.(bson.ObjectID)
+	return opponent, nil
+}
+
+func GetOpponents(ctx context.Context) ([]Opponent, error) {
+	coll, err := scoped(ctx, opponents)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{"name", 1}}).SetCollation(opponentCollation)
+	cursor, err := coll.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Opponent{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func GetOpponentByID(ctx context.Context, id string) (Opponent, error) {
+	coll, err := scoped(ctx, opponents)
+	if err != nil {
+		return Opponent{}, err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return Opponent{}, err
+	}
+
+	var opponent Opponent
+	err = coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&opponent)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Opponent{}, fmt.Errorf("opponent not found")
+		}
+		return Opponent{}, err
+	}
+	return opponent, nil
+}
+
+// GetTeamFixtures lists the team's home and away fixtures, newest first.
+func GetTeamFixtures(ctx context.Context, teamID string, filter FixtureFilter) ([]Fixture, error) {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return nil, err
+	}
+	objID, err := bson.ObjectIDFromHex(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{
+			{"$or", bson.A{
+				bson.D{{"home_team_id", objID}},
+				bson.D{{"away_team_id", objID}},
+			}},
+		}}},
+		{{"$match", filter.match()}},
+	}
+	pipeline = append(pipeline, fixtureDetails()...)
+	pipeline = append(pipeline, bson.D{{"$sort", bson.D{{"date", -1}}}})
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Fixture{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// MigrateFixtureTeams replaces the team names fixtures used to store with
+// references. Names matching one of the club's teams point at the team, any
+// other name becomes an opponent. Names differing only in case or spacing
+// are treated as the same club.
+func MigrateFixtureTeams() {
+	ctx := context.TODO()
+	coll := client.Database(db).Collection(fixtures)
+
+	cursor, err := coll.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"home_team": bson.M{"$type": "string"}, "home_team_id": bson.M{"$exists": false}},
+		bson.M{"away_team": bson.M{"$type": "string"}, "away_team_id": bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		log.Printf("Warning: could not migrate fixture teams: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	// Resolved names per club
+	resolved := map[string]bson.ObjectID{}
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID         bson.ObjectID `bson:"_id"`
+			HomeTeam   string        `bson:"home_team"`
+			AwayTeam   string        `bson:"away_team"`
+			HomeTeamID bson.ObjectID `bson:"home_team_id"`
+			AwayTeamID bson.ObjectID `bson:"away_team_id"`
+			TenantID   bson.ObjectID `bson:"tenant_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("Warning: could not read fixture: %v", err)
+			continue
+		}
+
+		tenantCtx := WithTenant(ctx, doc.TenantID)
+		set, unset := bson.M{}, bson.M{}
+		for _, side := range []struct {
+			nameField, idField, name string
+			linked                   bool
+		}{
+			{"home_team", "home_team_id", doc.HomeTeam, !doc.HomeTeamID.IsZero()},
+			{"away_team", "away_team_id", doc.AwayTeam, !doc.AwayTeamID.IsZero()},
+		} {
+			name := strings.TrimSpace(side.name)
+			if name == "" || side.linked {
+				continue
+			}
+			key := doc.TenantID.Hex() + ":" + strings.ToLower(name)
+			id, ok := resolved[key]
+			if !ok {
+				id, err = resolveTeamName(tenantCtx, name)
+				if err != nil {
+					// The name stays on the fixture so a later start can retry
+					log.Printf("Warning: could not resolve %s %q for fixture %s, left unmigrated: %v", side.nameField, name, doc.ID.Hex(), err)
+					continue
+				}
+				resolved[key] = id
+			}
+			set[side.idField] = id
+			unset[side.nameField] = ""
+		}
+		if len(set) == 0 {
+			continue
+		}
+
+		_, err := coll.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{
+			"$set":   set,
+			"$unset": unset,
+		})
+		if err != nil {
+			log.Printf("Warning: could not migrate fixture %s: %v", doc.ID.Hex(), err)
+			continue
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Linked %d fixtures to teams and opponents", migrated)
+	}
+}
+
+// resolveTeamName finds the club's team or opponent with the name, creating
+// an opponent when there is neither.
+func resolveTeamName(ctx context.Context, name string) (bson.ObjectID, error) {
+	for _, collection := range []string{teams, opponents} {
+		coll, err := scoped(ctx, collection)
+		if err != nil {
+			return bson.ObjectID{}, err
+		}
+		var found struct {
+			ID bson.ObjectID `bson:"_id"`
+		}
+		err = coll.FindOne(ctx, bson.M{"name": name}, options.FindOne().SetCollation(opponentCollation)).Decode(&found)
+		if err == nil {
+			return found.ID, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return bson.ObjectID{}, err
+		}
+	}
+
+	opponent, err := AddOpponent(ctx, name)
+	if err != nil {
+		return bson.ObjectID{}, err
+	}
+	return opponent.ID, nil
+}
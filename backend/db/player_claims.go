@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	playerClaims = "player_claims"
+)
+
+func EnsurePlayerClaimIndexes() {
+	coll := client.Database(db).Collection(playerClaims)
+	// One open claim per user at a time
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{"user_id", 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"status": ClaimPending,
+		}),
+	}
+	_, err := coll.Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on player_claims: %v", err)
+	}
+
+	// A user can only be linked to one player
+	indexModel = mongo.IndexModel{
+		Keys: bson.D{{"user_id", 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"user_id": bson.M{"$exists": true},
+		}),
+	}
+	_, err = client.Database(db).Collection(players).Indexes().CreateOne(context.TODO(), indexModel)
+	if err != nil {
+		log.Printf("Warning: could not create unique index on players.user_id: %v", err)
+	}
+}
+
+func CreatePlayerClaim(ctx context.Context, userID bson.ObjectID, player Player) (PlayerClaim, error) {
+	coll, err := scoped(ctx, playerClaims)
+	if err != nil {
+		return PlayerClaim{}, err
+	}
+
+	claim := PlayerClaim{
+		UserID:   userID,
+		PlayerID: player.ID,
+		TeamID:   player.TeamID,
+		Status:   ClaimPending,
+		Created:  time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, claim)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return PlayerClaim{}, fmt.Errorf("you already have a pending claim")
+		}
+		return PlayerClaim{}, err
+	}
+
+	claim.ID = result.InsertedID.(bson.ObjectID)
+	return claim, nil
+}
+
+func GetPlayerClaim(ctx context.Context, id string) (PlayerClaim, error) {
+	coll, err := scoped(ctx, playerClaims)
+	if err != nil {
+		return PlayerClaim{}, err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return PlayerClaim{}, err
+	}
+
+	var claim PlayerClaim
+	err = coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&claim)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return PlayerClaim{}, fmt.Errorf("claim not found")
+		}
+		return PlayerClaim{}, err
+	}
+	return claim, nil
+}
+
+// GetTeamPlayerClaims lists claims on the team's players with the player's
+// name and the claiming user's name and email. An empty status returns
+// claims of every status.
+func GetTeamPlayerClaims(ctx context.Context, teamID, status string) ([]PlayerClaim, error) {
+	coll, err := scoped(ctx, playerClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	objID, err := bson.ObjectIDFromHex(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.D{{"team_id", objID}}
+	if status != "" {
+		match = append(match, bson.E{"status", status})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$lookup", bson.D{
+			{"from", "players"},
+			{"localField", "player_id"},
+			{"foreignField", "_id"},
+			{"as", "playerDetails"},
+		}}},
+		{{"$lookup", bson.D{
+			{"from", "users"},
+			{"localField", "user_id"},
+			{"foreignField", "_id"},
+			{"as", "userDetails"},
+		}}},
+		{{"$addFields", bson.D{
+			{"player_name", bson.D{
+				{"$arrayElemAt", bson.A{"$playerDetails.name", 0}},
+			}},
+			{"user_name", bson.D{
+				{"$arrayElemAt", bson.A{"$userDetails.name", 0}},
+			}},
+			{"user_email", bson.D{
+				{"$arrayElemAt", bson.A{"$userDetails.email", 0}},
+			}},
+		}}},
+		{{"$project", bson.D{
+			{"playerDetails", 0},
+			{"userDetails", 0},
+		}}},
+		{{"$sort", bson.D{{"_id", -1}}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PlayerClaim
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DecidePlayerClaim approves or rejects a pending claim. Only one decision
+// can be made, so a claim that is no longer pending is not found.
+func DecidePlayerClaim(ctx context.Context, id bson.ObjectID, status string, decidedBy bson.ObjectID) (PlayerClaim, error) {
+	coll, err := scoped(ctx, playerClaims)
+	if err != nil {
+		return PlayerClaim{}, err
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var claim PlayerClaim
+	err = coll.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "status": ClaimPending},
+		bson.M{"$set": bson.M{"status": status, "decided_by": decidedBy}},
+		opts,
+	).Decode(&claim)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return PlayerClaim{}, fmt.Errorf("claim not found")
+		}
+		return PlayerClaim{}, err
+	}
+	return claim, nil
+}
+
+// LinkPlayerToUser links the player to the user's account. It fails when
+// the player is already linked or the user is linked to another player.
+func LinkPlayerToUser(ctx context.Context, playerID, userID bson.ObjectID) error {
+	coll, err := scoped(ctx, players)
+	if err != nil {
+		return err
+	}
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": playerID, "user_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"user_id": userID}},
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("user is already linked to a player")
+		}
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("player is already linked to a user")
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	seasons      = "seasons"
+	competitions = "competitions"
+)
+
+// ValidCompetitionType reports whether kind is a known competition type.
+func ValidCompetitionType(kind string) bool {
+	switch kind {
+	case CompetitionLeague, CompetitionCup, CompetitionFriendly:
+		return true
+	}
+	return false
+}
+
+// FixtureFilter narrows fixtures, and the stats taken from them, to a
+// season and a competition. Zero IDs match everything.
+type FixtureFilter struct {
+	SeasonID      bson.ObjectID
+	CompetitionID bson.ObjectID
+}
+
+func (f FixtureFilter) match() bson.D {
+	match := bson.D{}
+	if !f.SeasonID.IsZero() {
+		match = append(match, bson.E{"season_id", f.SeasonID})
+	}
+	if !f.CompetitionID.IsZero() {
+		match = append(match, bson.E{"competition_id", f.CompetitionID})
+	}
+	return match
+}
+
+func EnsureSeasonIndexes() {
+	for _, name := range []string{seasons, competitions} {
+		_, err := client.Database(db).Collection(name).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+			Keys:    bson.D{{"tenant_id", 1}, {"name", 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			log.Printf("Warning: could not create unique index on %s: %v", name, err)
+		}
+	}
+
+	for _, field := range []string{"season_id", "competition_id"} {
+		_, err := client.Database(db).Collection(fixtures).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+			Keys: bson.D{{field, 1}},
+		})
+		if err != nil {
+			log.Printf("Warning: could not create index on fixtures.%s: %v", field, err)
+		}
+	}
+}
+
+// AddSeason creates a season and assigns it the fixtures already played in
+// its dates, so history entered before seasons existed is kept. Seasons of
+// a club can't overlap.
+func AddSeason(ctx context.Context, name string, start, end time.Time) (Season, error) {
+	coll, err := scoped(ctx, seasons)
+	if err != nil {
+		return Season{}, err
+	}
+
+	season := Season{
+		Name:      strings.TrimSpace(name),
+		StartDate: start,
+		EndDate:   end,
+		Created:   time.Now().Format(format),
+	}
+	if season.Name == "" {
+		return Season{}, fmt.Errorf("season name is required")
+	}
+	if !end.After(start) {
+		return Season{}, fmt.Errorf("a season has to end after it starts")
+	}
+
+	existing, err := GetSeasons(ctx)
+	if err != nil {
+		return Season{}, err
+	}
+	for _, other := range existing {
+		if other.overlaps(start, end) {
+			return Season{}, fmt.Errorf("the season overlaps %s", other.Name)
+		}
+	}
+
+	result, err := coll.InsertOne(ctx, season)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Season{}, fmt.Errorf("a season with this name already exists")
+		}
+		return Season{}, err
+	}
+	season.ID = result.InsertedID.(bson.ObjectID)
+
+	collFixtures, err := scoped(ctx, fixtures)
+	if err != nil {
+		return season, err
+	}
+	_, err = collFixtures.UpdateMany(ctx,
+		bson.M{
+			"season_id": bson.M{"$exists": false},
+			"date":      bson.M{"$gte": start, "$lte": end},
+		},
+		bson.M{"$set": bson.M{"season_id": season.ID}},
+	)
+	if err != nil {
+		return season, err
+	}
+
+	return season, nil
+}
+
+// GetSeasons lists the club's seasons, latest first.
+func GetSeasons(ctx context.Context) ([]Season, error) {
+	coll, err := scoped(ctx, seasons)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"start_date", -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Season{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func GetSeasonByID(ctx context.Context, id string) (Season, error) {
+	coll, err := scoped(ctx, seasons)
+	if err != nil {
+		return Season{}, err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return Season{}, err
+	}
+
+	var season Season
+	err = coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&season)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Season{}, fmt.Errorf("season not found")
+		}
+		return Season{}, err
+	}
+	return season, nil
+}
+
+// GetSeasonOn finds the season the date falls in.
+func GetSeasonOn(ctx context.Context, date time.Time) (Season, error) {
+	coll, err := scoped(ctx, seasons)
+	if err != nil {
+		return Season{}, err
+	}
+
+	var season Season
+	err = coll.FindOne(ctx, bson.M{
+		"start_date": bson.M{"$lte": date},
+		"end_date":   bson.M{"$gte": date},
+	}).Decode(&season)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Season{}, fmt.Errorf("season not found")
+		}
+		return Season{}, err
+	}
+	return season, nil
+}
+
+func AddCompetition(ctx context.Context, name, kind string) (Competition, error) {
+	coll, err := scoped(ctx, competitions)
+	if err != nil {
+		return Competition{}, err
+	}
+
+	competition := Competition{
+		Name:    strings.TrimSpace(name),
+		Type:    kind,
+		Created: time.Now().Format(format),
+	}
+	if competition.Name == "" {
+		return Competition{}, fmt.Errorf("competition name is required")
+	}
+	if !ValidCompetitionType(kind) {
+		return Competition{}, fmt.Errorf("competition type must be league, cup or friendly")
+	}
+
+	result, err := coll.InsertOne(ctx, competition)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Competition{}, fmt.Errorf("a competition with this name already exists")
+		}
+		return Competition{}, err
+	}
+
+	competition.ID = result.InsertedID.(bson.ObjectID)
+	return competition, nil
+}
+
+func GetCompetitions(ctx context.Context) ([]Competition, error) {
+	coll, err := scoped(ctx, competitions)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"name", 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Competition{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func GetCompetitionByID(ctx context.Context, id string) (Competition, error) {
+	coll, err := scoped(ctx, competitions)
+	if err != nil {
+		return Competition{}, err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return Competition{}, err
+	}
+
+	var competition Competition
+	err = coll.FindOne(ctx, bson.M{"_id": objID}).Decode(&competition)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Competition{}, fmt.Errorf("competition not found")
+		}
+		return Competition{}, err
+	}
+	return competition, nil
+}
+
+// AssignFixture puts the fixture in a season and a competition. Zero IDs
+// leave that assignment as it is.
+func AssignFixture(ctx context.Context, id string, seasonID, competitionID bson.ObjectID) error {
+	coll, err := scoped(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{}
+	if !seasonID.IsZero() {
+		set["season_id"] = seasonID
+	}
+	if !competitionID.IsZero() {
+		set["competition_id"] = competitionID
+	}
+	if len(set) == 0 {
+		return fmt.Errorf("nothing to assign")
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrFixtureNotFound
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestSeasonOverlaps(t *testing.T) {
+	season := Season{StartDate: day(2024, time.August, 1), EndDate: day(2025, time.May, 31)}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{"before", day(2023, time.August, 1), day(2024, time.May, 31), false},
+		{"after", day(2025, time.August, 1), day(2026, time.May, 31), false},
+		{"ends the day it starts", day(2023, time.August, 1), day(2024, time.August, 1), true},
+		{"starts the day it ends", day(2025, time.May, 31), day(2026, time.May, 31), true},
+		{"inside", day(2024, time.September, 1), day(2024, time.December, 31), true},
+		{"around", day(2024, time.January, 1), day(2025, time.December, 31), true},
+		{"same dates", season.StartDate, season.EndDate, true},
+	}
+
+	for _, tt := range tests {
+		if got := season.overlaps(tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: overlaps = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFixtureFilterMatch(t *testing.T) {
+	season, competition := bson.NewObjectID(), bson.NewObjectID()
+
+	tests := []struct {
+		name   string
+		filter FixtureFilter
+		want   bson.D
+	}{
+		{"everything", FixtureFilter{}, bson.D{}},
+		{"season", FixtureFilter{SeasonID: season}, bson.D{{"season_id", season}}},
+		{"competition", FixtureFilter{CompetitionID: competition}, bson.D{{"competition_id", competition}}},
+		{"both", FixtureFilter{SeasonID: season, CompetitionID: competition},
+			bson.D{{"season_id", season}, {"competition_id", competition}}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.match(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddSeasonRejectsOverlap(t *testing.T) {
+	ctx := testClub(t)
+
+	if _, err := AddSeason(ctx, "2024/25", day(2024, time.August, 1), day(2025, time.May, 31)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddSeason(ctx, "Summer 2025", day(2025, time.May, 1), day(2025, time.July, 31)); err == nil {
+		t.Fatal("added a season overlapping another")
+	}
+	if _, err := AddSeason(ctx, "2025/26", day(2025, time.August, 1), day(2026, time.May, 31)); err != nil {
+		t.Fatalf("next season: %v", err)
+	}
+
+	// Other clubs' seasons don't count
+	if _, err := AddSeason(testClub(t), "2024/25", day(2024, time.August, 1), day(2025, time.May, 31)); err != nil {
+		t.Fatalf("same season in another club: %v", err)
+	}
+}
+
+// Fixtures played before a season was added are put in it, unless they
+// were already assigned to another.
+func TestAddSeasonBackfillsFixtures(t *testing.T) {
+	ctx := testClub(t)
+	team, _ := testPlayer(t, ctx)
+	opponent, err := AddOpponent(ctx, "Blues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addFixture := func(date time.Time) Fixture {
+		t.Helper()
+		fixture, err := AddFixture(ctx, date, team.ID, opponent.ID, nil, nil, "", "", "", bson.ObjectID{}, bson.ObjectID{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fixture
+	}
+
+	inside := addFixture(day(2024, time.October, 5))
+	outside := addFixture(day(2025, time.July, 5))
+	assigned := addFixture(day(2024, time.November, 9))
+
+	earlier, err := AddSeason(ctx, "2023/24", day(2023, time.August, 1), day(2024, time.May, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssignFixture(ctx, assigned.ID.Hex(), earlier.ID, bson.ObjectID{}); err != nil {
+		t.Fatal(err)
+	}
+
+	season, err := AddSeason(ctx, "2024/25", day(2024, time.August, 1), day(2025, time.May, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		fixture Fixture
+		want    bson.ObjectID
+	}{
+		{inside, season.ID},
+		{outside, bson.ObjectID{}},
+		{assigned, earlier.ID},
+	} {
+		fixture, err := GetFixtureByID(ctx, tt.fixture.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fixture.SeasonID != tt.want {
+			t.Errorf("fixture on %s: season = %s, want %s", fixture.Date.Format(time.DateOnly), fixture.SeasonID.Hex(), tt.want.Hex())
+		}
+	}
+}
+
+func TestAssignFixtureNotFound(t *testing.T) {
+	ctx := testClub(t)
+	err := AssignFixture(ctx, bson.NewObjectID().Hex(), bson.NewObjectID(), bson.ObjectID{})
+	if !errors.Is(err, ErrFixtureNotFound) {
+		t.Fatalf("assigning a missing fixture: %v, want ErrFixtureNotFound", err)
+	}
+}
@@ -40,6 +40,23 @@ func Connect() {
 	fmt.Println("connected to MongoDB...")
 
 	EnsureUserIndexes()
+	EnsureRefreshTokenIndexes()
+	EnsureSessionIndexes()
+	EnsureUserTokenIndexes()
+	EnsureLoginAttemptIndexes()
+	EnsureAPIKeyIndexes()
+	EnsureOIDCLoginIndexes()
+	EnsureMembershipIndexes()
+	EnsurePlayerClaimIndexes()
+	EnsureInviteIndexes()
+	EnsureOpponentIndexes()
+	EnsureSeasonIndexes()
+	EnsureAuditIndexes()
+	EnsureClubIndexes()
+	MigrateDefaultTenant()
+	MigrateFixtureStatus()
+	MigrateTypedFields()
+	MigrateFixtureTeams()
 }
 
 func Stop() {
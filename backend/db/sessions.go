@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	sessions = "sessions"
+
+	// Don't write last_seen on every request
+	sessionTouchInterval = time.Minute
+)
+
+func EnsureSessionIndexes() {
+	coll := client.Database(db).Collection(sessions)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{"user_id", 1}},
+		},
+		{
+			// Sessions end with their last refresh token
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := coll.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		log.Printf("Warning: could not create indexes on sessions: %v", err)
+	}
+}
+
+// SaveSession records the device a refresh token family was issued to. It
+// runs on login and on every refresh, so the session follows the device's
+// latest IP and lives as long as its newest refresh token.
+func SaveSession(ctx context.Context, id, userID bson.ObjectID, userAgent, ip string, expiresAt time.Time) error {
+	coll := client.Database(db).Collection(sessions)
+
+	now := time.Now()
+	_, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$set": bson.M{
+				"user_agent": userAgent,
+				"ip":         ip,
+				"last_seen":  now,
+				"expires_at": expiresAt,
+			},
+			"$setOnInsert": bson.M{
+				"user_id": userID,
+				"revoked": false,
+				"created": now.Format(format),
+			},
+		},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// TouchSession returns the session and records that it was used, at most
+// once a minute.
+func TouchSession(ctx context.Context, id bson.ObjectID, now time.Time) (Session, error) {
+	coll := client.Database(db).Collection(sessions)
+
+	var session Session
+	err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Session{}, fmt.Errorf("session not found")
+		}
+		return Session{}, err
+	}
+
+	if !session.Revoked && now.Sub(session.LastSeen) >= sessionTouchInterval {
+		_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_seen": now}})
+		if err != nil {
+			return session, err
+		}
+		session.LastSeen = now
+	}
+	return session, nil
+}
+
+// GetUserSessions lists the user's sessions that can still be used, most
+// recently seen first.
+func GetUserSessions(ctx context.Context, userID bson.ObjectID) ([]Session, error) {
+	coll := client.Database(db).Collection(sessions)
+
+	opts := options.Find().SetSort(bson.D{{"last_seen", -1}})
+	cursor, err := coll.Find(ctx, bson.M{
+		"user_id":    userID,
+		"revoked":    false,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Session
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// RevokeSession ends one of the user's sessions along with its refresh
+// tokens.
+func RevokeSession(ctx context.Context, userID, id bson.ObjectID) error {
+	coll := client.Database(db).Collection(sessions)
+
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return RevokeRefreshTokenFamily(ctx, id)
+}
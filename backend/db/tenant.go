@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ErrNoTenant is returned when a tenant scoped query runs without a club in
+// its context.
+var ErrNoTenant = errors.New("no tenant in context")
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx that scopes every query to the club.
+func WithTenant(ctx context.Context, tenantID bson.ObjectID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func TenantFromContext(ctx context.Context) (bson.ObjectID, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(bson.ObjectID)
+	if !ok || tenantID.IsZero() {
+		return bson.ObjectID{}, false
+	}
+	return tenantID, true
+}
+
+// scopedCollection wraps a collection so that reads only match the tenant's
+// documents and writes are stamped with its tenant_id. Every write is also
+// recorded in the audit log.
+type scopedCollection struct {
+	coll     *mongo.Collection
+	tenantID bson.ObjectID
+}
+
+func scoped(ctx context.Context, name string) (*scopedCollection, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return &scopedCollection{
+		coll:     client.Database(db).Collection(name),
+		tenantID: tenantID,
+	}, nil
+}
+
+func (s *scopedCollection) filter(filter any) bson.D {
+	return bson.D{
+		{"tenant_id", s.tenantID},
+		{"$and", bson.A{filter}},
+	}
+}
+
+// stamp converts document to a bson.D carrying the tenant_id, replacing any
+// tenant_id the caller set.
+func (s *scopedCollection) stamp(document any) (bson.D, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	stamped := make(bson.D, 0, len(doc)+1)
+	for _, elem := range doc {
+		if elem.Key != "tenant_id" {
+			stamped = append(stamped, elem)
+		}
+	}
+	return append(stamped, bson.E{"tenant_id", s.tenantID}), nil
+}
+
+func (s *scopedCollection) InsertOne(ctx context.Context, document any) (*mongo.InsertOneResult, error) {
+	doc, err := s.stamp(document)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.coll.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, AuditCreate, result.InsertedID, nil, toMap(doc))
+	return result, nil
+}
+
+func (s *scopedCollection) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error) {
+	return s.coll.Find(ctx, s.filter(filter), opts...)
+}
+
+func (s *scopedCollection) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
+	return s.coll.FindOne(ctx, s.filter(filter), opts...)
+}
+
+func (s *scopedCollection) FindOneAndUpdate(ctx context.Context, filter, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+	before := s.snapshot(ctx, filter, 1)
+
+	result := s.coll.FindOneAndUpdate(ctx, s.filter(filter), update, opts...)
+	if result.Err() == nil && len(before) > 0 {
+		s.audit(ctx, AuditUpdate, before[0]["_id"], before[0], s.snapshotByID(ctx, before[0]["_id"]))
+	}
+	return result
+}
+
+func (s *scopedCollection) UpdateOne(ctx context.Context, filter, update any) (*mongo.UpdateResult, error) {
+	before := s.snapshot(ctx, filter, 1)
+
+	result, err := s.coll.UpdateOne(ctx, s.filter(filter), update)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount > 0 && len(before) > 0 {
+		s.audit(ctx, AuditUpdate, before[0]["_id"], before[0], s.snapshotByID(ctx, before[0]["_id"]))
+	}
+	return result, nil
+}
+
+func (s *scopedCollection) UpdateByID(ctx context.Context, id bson.ObjectID, update any) (*mongo.UpdateResult, error) {
+	return s.UpdateOne(ctx, bson.D{{"_id", id}}, update)
+}
+
+func (s *scopedCollection) UpdateMany(ctx context.Context, filter, update any) (*mongo.UpdateResult, error) {
+	before := s.snapshot(ctx, filter, 0)
+
+	result, err := s.coll.UpdateMany(ctx, s.filter(filter), update)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount > 0 {
+		for _, doc := range before {
+			s.audit(ctx, AuditUpdate, doc["_id"], doc, s.snapshotByID(ctx, doc["_id"]))
+		}
+	}
+	return result, nil
+}
+
+func (s *scopedCollection) DeleteOne(ctx context.Context, filter any) (*mongo.DeleteResult, error) {
+	before := s.snapshot(ctx, filter, 1)
+
+	result, err := s.coll.DeleteOne(ctx, s.filter(filter))
+	if err != nil {
+		return nil, err
+	}
+	if result.DeletedCount > 0 && len(before) > 0 {
+		s.audit(ctx, AuditDelete, before[0]["_id"], before[0], nil)
+	}
+	return result, nil
+}
+
+func (s *scopedCollection) CountDocuments(ctx context.Context, filter any) (int64, error) {
+	return s.coll.CountDocuments(ctx, s.filter(filter))
+}
+
+// Aggregate prepends a tenant $match and adds one to every $lookup, so no
+// stage sees another tenant's documents.
+func (s *scopedCollection) Aggregate(ctx context.Context, pipeline mongo.Pipeline) (*mongo.Cursor, error) {
+	scopedPipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"tenant_id", s.tenantID}}}},
+	}
+	for _, stage := range pipeline {
+		scopedPipeline = append(scopedPipeline, s.scopeLookup(stage))
+	}
+	return s.coll.Aggregate(ctx, scopedPipeline)
+}
+
+// scopeLookup runs a tenant $match first in a $lookup stage's pipeline and
+// scopes any lookups nested in it. Other stages are returned unchanged.
+// Lookups with both localField and a pipeline need MongoDB 5.0.
+func (s *scopedCollection) scopeLookup(stage bson.D) bson.D {
+	if len(stage) != 1 || stage[0].Key != "$lookup" {
+		return stage
+	}
+	spec, ok := stage[0].Value.(bson.D)
+	if !ok {
+		return stage
+	}
+
+	lookup := bson.D{}
+	pipeline := bson.A{bson.D{{"$match", bson.D{{"tenant_id", s.tenantID}}}}}
+	for _, field := range spec {
+		if field.Key != "pipeline" {
+			lookup = append(lookup, field)
+			continue
+		}
+		switch inner := field.Value.(type) {
+		case mongo.Pipeline:
+			for _, innerStage := range inner {
+				pipeline = append(pipeline, s.scopeLookup(innerStage))
+			}
+		case bson.A:
+			for _, innerStage := range inner {
+				if d, ok := innerStage.(bson.D); ok {
+					innerStage = s.scopeLookup(d)
+				}
+				pipeline = append(pipeline, innerStage)
+			}
+		}
+	}
+	lookup = append(lookup, bson.E{"pipeline", pipeline})
+
+	return bson.D{{"$lookup", lookup}}
+}
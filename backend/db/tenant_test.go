@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestScopedNeedsTenant(t *testing.T) {
+	if _, err := scoped(context.Background(), players); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("scoped without tenant: got %v, want ErrNoTenant", err)
+	}
+	if _, err := scoped(WithTenant(context.Background(), bson.ObjectID{}), players); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("scoped with zero tenant: got %v, want ErrNoTenant", err)
+	}
+}
+
+func TestScopedFilter(t *testing.T) {
+	tenant := bson.NewObjectID()
+	s := &scopedCollection{tenantID: tenant}
+
+	got := s.filter(bson.M{"_id": 1})
+	want := bson.D{{"tenant_id", tenant}, {"$and", bson.A{bson.M{"_id": 1}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("filter = %v, want %v", got, want)
+	}
+}
+
+func TestScopedStamp(t *testing.T) {
+	tenant := bson.NewObjectID()
+	s := &scopedCollection{tenantID: tenant}
+
+	doc, err := s.stamp(Team{Name: "Reds", TenantID: bson.NewObjectID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	for _, e := range doc {
+		if e.Key != "tenant_id" {
+			continue
+		}
+		count++
+		if e.Value != tenant {
+			t.Errorf("tenant_id = %v, want %v", e.Value, tenant)
+		}
+	}
+	if count != 1 {
+		t.Errorf("document has %d tenant_id fields, want 1", count)
+	}
+}
+
+func TestScopeLookup(t *testing.T) {
+	tenant := bson.NewObjectID()
+	s := &scopedCollection{tenantID: tenant}
+	tenantMatch := bson.D{{"$match", bson.D{{"tenant_id", tenant}}}}
+
+	tests := []struct {
+		name  string
+		stage bson.D
+		want  bson.D
+	}{
+		{
+			name:  "other stages are unchanged",
+			stage: bson.D{{"$match", bson.D{{"name", "Reds"}}}},
+			want:  bson.D{{"$match", bson.D{{"name", "Reds"}}}},
+		},
+		{
+			name: "local and foreign field lookup",
+			stage: bson.D{{"$lookup", bson.D{
+				{"from", "players"},
+				{"localField", "man_of_the_match"},
+				{"foreignField", "_id"},
+				{"as", "motm"},
+			}}},
+			want: bson.D{{"$lookup", bson.D{
+				{"from", "players"},
+				{"localField", "man_of_the_match"},
+				{"foreignField", "_id"},
+				{"as", "motm"},
+				{"pipeline", bson.A{tenantMatch}},
+			}}},
+		},
+		{
+			name: "existing pipeline runs after the tenant match",
+			stage: bson.D{{"$lookup", bson.D{
+				{"from", "teams"},
+				{"pipeline", mongo.Pipeline{{{"$limit", 1}}}},
+				{"as", "team"},
+			}}},
+			want: bson.D{{"$lookup", bson.D{
+				{"from", "teams"},
+				{"as", "team"},
+				{"pipeline", bson.A{tenantMatch, bson.D{{"$limit", 1}}}},
+			}}},
+		},
+		{
+			name: "nested lookups are scoped",
+			stage: bson.D{{"$lookup", bson.D{
+				{"from", "teams"},
+				{"pipeline", bson.A{bson.D{{"$lookup", bson.D{{"from", "players"}, {"as", "p"}}}}}},
+				{"as", "team"},
+			}}},
+			want: bson.D{{"$lookup", bson.D{
+				{"from", "teams"},
+				{"as", "team"},
+				{"pipeline", bson.A{
+					tenantMatch,
+					bson.D{{"$lookup", bson.D{{"from", "players"}, {"as", "p"}, {"pipeline", bson.A{tenantMatch}}}}},
+				}},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.scopeLookup(tt.stage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scopeLookup = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
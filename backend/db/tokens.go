@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	refreshTokens = "refresh_tokens"
+	userTokens    = "user_tokens"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token that has already
+// been rotated is presented again.
+var ErrRefreshTokenReused = errors.New("refresh token already used")
+
+func EnsureRefreshTokenIndexes() {
+	coll := client.Database(db).Collection(refreshTokens)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"token_hash", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{"family_id", 1}},
+		},
+		{
+			// Let Mongo clean up expired tokens
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := coll.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		log.Printf("Warning: could not create indexes on refresh_tokens: %v", err)
+	}
+}
+
+func EnsureUserTokenIndexes() {
+	coll := client.Database(db).Collection(userTokens)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"token_hash", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := coll.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		log.Printf("Warning: could not create indexes on user_tokens: %v", err)
+	}
+}
+
+func CreateRefreshToken(ctx context.Context, userID, familyID bson.ObjectID, tokenHash string, expiresAt time.Time) (RefreshToken, error) {
+	coll := client.Database(db).Collection(refreshTokens)
+
+	token := RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		Created:   time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, token)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	token.ID = result.InsertedID.(bson.ObjectID)
+	return token, nil
+}
+
+func GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	coll := client.Database(db).Collection(refreshTokens)
+
+	var token RefreshToken
+	err := coll.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return RefreshToken{}, fmt.Errorf("refresh token not found")
+		}
+		return RefreshToken{}, err
+	}
+	return token, nil
+}
+
+// MarkRefreshTokenUsed flags a token as rotated. Only one caller can win the
+// update, so a concurrent replay gets ErrRefreshTokenReused.
+func MarkRefreshTokenUsed(ctx context.Context, id bson.ObjectID) error {
+	coll := client.Database(db).Collection(refreshTokens)
+
+	result, err := coll.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "used": false, "revoked": false},
+		bson.M{"$set": bson.M{"used": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// RevokeRefreshTokenFamily ends the session the family belongs to.
+func RevokeRefreshTokenFamily(ctx context.Context, familyID bson.ObjectID) error {
+	coll := client.Database(db).Collection(refreshTokens)
+	_, err := coll.UpdateMany(
+		ctx,
+		bson.M{"family_id": familyID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Database(db).Collection(sessions).UpdateOne(
+		ctx,
+		bson.M{"_id": familyID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+// RevokeUserRefreshTokens ends every session of the user.
+func RevokeUserRefreshTokens(ctx context.Context, userID bson.ObjectID) error {
+	coll := client.Database(db).Collection(refreshTokens)
+	_, err := coll.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Database(db).Collection(sessions).UpdateMany(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+// CreateUserToken stores a new token for the purpose and invalidates any
+// earlier ones, so only the latest email link works.
+func CreateUserToken(ctx context.Context, userID bson.ObjectID, purpose, tokenHash string, expiresAt time.Time) (UserToken, error) {
+	coll := client.Database(db).Collection(userTokens)
+
+	_, err := coll.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used": false},
+		bson.M{"$set": bson.M{"used": true}},
+	)
+	if err != nil {
+		return UserToken{}, err
+	}
+
+	token := UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		Created:   time.Now().Format(format),
+	}
+
+	result, err := coll.InsertOne(ctx, token)
+	if err != nil {
+		return UserToken{}, err
+	}
+
+	token.ID = result.InsertedID.(bson.ObjectID)
+	return token, nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+func ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (UserToken, error) {
+	coll := client.Database(db).Collection(userTokens)
+
+	var token UserToken
+	err := coll.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"token_hash": tokenHash,
+			"purpose":    purpose,
+			"used":       false,
+			"expires_at": bson.M{"$gt": time.Now()},
+		},
+		bson.M{"$set": bson.M{"used": true}},
+	).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return UserToken{}, fmt.Errorf("token is invalid or expired")
+		}
+		return UserToken{}, err
+	}
+	return token, nil
+}
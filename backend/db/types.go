@@ -1,6 +1,8 @@
 package db
 
 import (
+	"encoding/json"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -10,18 +12,260 @@ const (
 	format = "2006-01-02T15:04:05.000Z"
 )
 
+// Club is a tenant. Every other document carries its club's tenant_id.
+type Club struct {
+	ID      bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name    string        `bson:"name" json:"name"`
+	Slug    string        `bson:"slug" json:"slug"`
+	Created string        `bson:"created" json:"created"`
+}
+
+const (
+	RoleAdmin  = "admin"
+	RoleCoach  = "coach"
+	RolePlayer = "player"
+	RoleViewer = "viewer"
+)
+
+// ValidRole reports whether role is one of the known user roles.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleCoach, RolePlayer, RoleViewer:
+		return true
+	}
+	return false
+}
+
 type User struct {
+	ID       bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email    string        `bson:"email" json:"email"`
+	Password string        `bson:"password" json:"-"`
+	Name     string        `bson:"name" json:"name"`
+	Role     string        `bson:"role" json:"role"`
+	Verified bool          `bson:"verified" json:"verified"`
+	Disabled bool          `bson:"disabled" json:"disabled"`
+
+	// Two-factor authentication. The secret is set on setup and only used
+	// once TOTPEnabled is true. Recovery codes are stored hashed.
+	TOTPSecret    string   `bson:"totp_secret,omitempty" json:"-"`
+	TOTPEnabled   bool     `bson:"totp_enabled" json:"totp_enabled"`
+	TOTPLastStep  int64    `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes []string `bson:"recovery_codes,omitempty" json:"-"`
+
+	// Accounts at OpenID Connect providers that can log in as this user
+	Identities []Identity `bson:"identities,omitempty" json:"-"`
+
+	TenantID bson.ObjectID `bson:"tenant_id" json:"tenant_id"`
+	Created  string        `bson:"created" json:"created"`
+}
+
+// GetRole returns the user's role, treating accounts created before roles
+// existed as viewers.
+func (u User) GetRole() string {
+	if u.Role == "" {
+		return RoleViewer
+	}
+	return u.Role
+}
+
+const (
+	TeamRoleCoach     = "coach"
+	TeamRoleAssistant = "assistant"
+	TeamRolePlayer    = "player"
+	TeamRoleParent    = "parent"
+
+	MembershipInvited = "invited"
+	MembershipActive  = "active"
+)
+
+// Membership links a user to a team with a per-team role.
+type Membership struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    bson.ObjectID `bson:"user_id" json:"user_id"`
+	TeamID    bson.ObjectID `bson:"team_id" json:"team_id"`
+	Role      string        `bson:"role" json:"role"`
+	Status    string        `bson:"status" json:"status"`
+	InvitedBy bson.ObjectID `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+	Created   string        `bson:"created" json:"created"`
+	TeamName  string        `bson:"team_name,omitempty" json:"team_name,omitempty"`
+	UserName  string        `bson:"user_name,omitempty" json:"user_name,omitempty"`
+	UserEmail string        `bson:"user_email,omitempty" json:"user_email,omitempty"`
+	TenantID  bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+// CanManage reports whether the membership allows editing the team's data.
+func (m Membership) CanManage() bool {
+	return m.Status == MembershipActive && (m.Role == TeamRoleCoach || m.Role == TeamRoleAssistant)
+}
+
+const (
+	ClaimPending  = "pending"
+	ClaimApproved = "approved"
+	ClaimRejected = "rejected"
+)
+
+// PlayerClaim is a user asking to be linked to a player record. A manager of
+// the player's team approves or rejects it.
+type PlayerClaim struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     bson.ObjectID `bson:"user_id" json:"user_id"`
+	PlayerID   bson.ObjectID `bson:"player_id" json:"player_id"`
+	TeamID     bson.ObjectID `bson:"team_id" json:"team_id"`
+	Status     string        `bson:"status" json:"status"`
+	DecidedBy  bson.ObjectID `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	Created    string        `bson:"created" json:"created"`
+	PlayerName string        `bson:"player_name,omitempty" json:"player_name,omitempty"`
+	UserName   string        `bson:"user_name,omitempty" json:"user_name,omitempty"`
+	UserEmail  string        `bson:"user_email,omitempty" json:"user_email,omitempty"`
+	TenantID   bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+
+	AuditImpersonate        = "impersonate"
+	AuditForcePasswordReset = "force_password_reset"
+)
+
+// Change is the old and new value of one field. From is empty for fields
+// that were added and To for fields that were removed.
+type Change struct {
+	From any `bson:"from,omitempty" json:"from,omitempty"`
+	To   any `bson:"to,omitempty" json:"to,omitempty"`
+}
+
+// AuditEntry records one change to a document. Entries are only ever
+// inserted, never updated or deleted.
+type AuditEntry struct {
+	ID      bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	ActorID bson.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"` // Empty for changes made by the server itself
+	// Admin acting as ActorID through impersonation
+	ImpersonatorID bson.ObjectID     `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"`
+	Action         string            `bson:"action" json:"action"`
+	Entity         string            `bson:"entity" json:"entity"` // Collection the document lives in
+	EntityID       bson.ObjectID     `bson:"entity_id" json:"entity_id"`
+	Changes        map[string]Change `bson:"changes" json:"changes"`
+	Timestamp      time.Time         `bson:"timestamp" json:"timestamp"`
+	ActorName      string            `bson:"actor_name,omitempty" json:"actor_name,omitempty"`
+	ActorEmail     string            `bson:"actor_email,omitempty" json:"actor_email,omitempty"`
+	TenantID       bson.ObjectID     `bson:"tenant_id,omitempty" json:"-"`
+}
+
+type RefreshToken struct {
 	ID        bson.ObjectID `bson:"_id,omitempty" json:"id"`
-	Email     string        `bson:"email" json:"email"`
-	Password  string        `bson:"password" json:"-"`
-	Name      string        `bson:"name" json:"name"`
+	UserID    bson.ObjectID `bson:"user_id" json:"user_id"`
+	FamilyID  bson.ObjectID `bson:"family_id" json:"family_id"` // Shared by every token rotated from the same login
+	TokenHash string        `bson:"token_hash" json:"-"`
+	Used      bool          `bson:"used" json:"used"`
+	Revoked   bool          `bson:"revoked" json:"revoked"`
+	ExpiresAt time.Time     `bson:"expires_at" json:"expires_at"`
 	Created   string        `bson:"created" json:"created"`
 }
 
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// Invite lets people register straight into a team with a role. The code
+// is shown once and only its hash is stored. It works until it expires, is
+// revoked or has been used MaxUses times.
+type Invite struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	TeamID    bson.ObjectID `bson:"team_id" json:"team_id"`
+	Role      string        `bson:"role" json:"role"` // Team role
+	CodeHash  string        `bson:"code_hash" json:"-"`
+	MaxUses   int           `bson:"max_uses" json:"max_uses"`
+	Uses      int           `bson:"uses" json:"uses"`
+	CreatedBy bson.ObjectID `bson:"created_by" json:"created_by"`
+	Revoked   bool          `bson:"revoked" json:"revoked"`
+	ExpiresAt time.Time     `bson:"expires_at" json:"expires_at"`
+	Created   string        `bson:"created" json:"created"`
+	TeamName  string        `bson:"team_name,omitempty" json:"team_name,omitempty"`
+	TenantID  bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+// Session is one logged in device. Its ID is the refresh token family, which
+// access tokens carry as their sid claim.
+type Session struct {
+	ID        bson.ObjectID `bson:"_id" json:"id"`
+	UserID    bson.ObjectID `bson:"user_id" json:"user_id"`
+	UserAgent string        `bson:"user_agent" json:"user_agent"`
+	IP        string        `bson:"ip" json:"ip"`
+	LastSeen  time.Time     `bson:"last_seen" json:"last_seen"`
+	Revoked   bool          `bson:"revoked" json:"-"`
+	ExpiresAt time.Time     `bson:"expires_at" json:"expires_at"`
+	Created   string        `bson:"created" json:"created"`
+}
+
+// UserToken is a single-use token emailed to a user, such as a password
+// reset link. Only the hash is stored.
+type UserToken struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    bson.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose   string        `bson:"purpose" json:"purpose"`
+	TokenHash string        `bson:"token_hash" json:"-"`
+	Used      bool          `bson:"used" json:"used"`
+	ExpiresAt time.Time     `bson:"expires_at" json:"expires_at"`
+	Created   string        `bson:"created" json:"created"`
+}
+
+// Identity is a user's account at an OpenID Connect provider.
+type Identity struct {
+	Issuer  string `bson:"issuer" json:"issuer"`
+	Subject string `bson:"subject" json:"subject"`
+}
+
+// OIDCLogin holds what we need to finish a provider login between sending
+// the user to the provider and the callback. Only the hash of the state is
+// stored.
+type OIDCLogin struct {
+	ID           bson.ObjectID `bson:"_id,omitempty"`
+	StateHash    string        `bson:"state_hash"`
+	Nonce        string        `bson:"nonce"`
+	CodeVerifier string        `bson:"code_verifier"`
+	Club         string        `bson:"club"` // Slug of the club new accounts join
+	ExpiresAt    time.Time     `bson:"expires_at"`
+	Created      string        `bson:"created"`
+}
+
+const (
+	ScopeRead          = "read"
+	ScopePlayersWrite  = "players:write"
+	ScopeTeamsWrite    = "teams:write"
+	ScopeFixturesWrite = "fixtures:write"
+)
+
+// ValidScope reports whether scope can be granted to an API key.
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopePlayersWrite, ScopeTeamsWrite, ScopeFixturesWrite:
+		return true
+	}
+	return false
+}
+
+// APIKey lets scripts act as the user that created it, limited to Scopes.
+// Only the hash of the key is stored.
+type APIKey struct {
+	ID       bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   bson.ObjectID `bson:"user_id" json:"user_id"`
+	Name     string        `bson:"name" json:"name"`
+	Prefix   string        `bson:"prefix" json:"prefix"` // Start of the key so users can tell keys apart
+	KeyHash  string        `bson:"key_hash" json:"-"`
+	Scopes   []string      `bson:"scopes" json:"scopes"`
+	Revoked  bool          `bson:"revoked" json:"revoked"`
+	LastUsed *time.Time    `bson:"last_used,omitempty" json:"last_used,omitempty"`
+	Created  string        `bson:"created" json:"created"`
+	TenantID bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
 type Player struct {
 	ID            bson.ObjectID `bson:"_id,omitempty"`
 	Name          string        `bson:"name"`
-	Age           string        `bson:"age"`
+	DOB           time.Time     `bson:"dob,omitempty"`
 	Position      string        `bson:"position"`
 	FunFact       string        `bson:"fun_fact"`
 	Goals         int           `bson:"goals"`
@@ -32,24 +276,84 @@ type Player struct {
 	Created       string        `bson:"created"`
 	TeamID        bson.ObjectID `bson:"team_id"`
 	TeamName      string        `bson:"team_name,omitempty"`
+	UserID        bson.ObjectID `bson:"user_id,omitempty"` // Account linked through an approved claim
+	TenantID      bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+// Age is the player's age in whole years on the given day, or -1 when the
+// date of birth isn't known.
+func (p Player) Age(on time.Time) int {
+	if p.DOB.IsZero() {
+		return -1
+	}
+	age := on.Year() - p.DOB.Year()
+	if on.Month() < p.DOB.Month() || (on.Month() == p.DOB.Month() && on.Day() < p.DOB.Day()) {
+		age--
+	}
+	return age
+}
+
+// MarshalJSON adds the player's current age, which is not stored.
+func (p Player) MarshalJSON() ([]byte, error) {
+	type player Player
+	out := struct {
+		player
+		DOB *time.Time
+		Age *int
+	}{player: player(p)}
+
+	if age := p.Age(time.Now()); age >= 0 {
+		out.DOB = &p.DOB
+		out.Age = &age
+	}
+	return json.Marshal(out)
 }
 
 type Team struct {
-	ID      bson.ObjectID   `bson:"_id,omitempty"`
-	Name    string          `bson:"name"`
-	Coach   string          `bson:"coach"`
-	Players []bson.ObjectID `bson:"players"` // List of Player IDs
-	Founded string          `bson:"founded"`
-	Created string          `bson:"created"`
+	ID       bson.ObjectID   `bson:"_id,omitempty"`
+	Name     string          `bson:"name"`
+	Coach    string          `bson:"coach"`
+	Players  []bson.ObjectID `bson:"players"`           // List of Player IDs
+	Founded  int             `bson:"founded,omitempty"` // Year
+	Created  string          `bson:"created"`
+	TenantID bson.ObjectID   `bson:"tenant_id,omitempty" json:"-"`
+}
+
+const (
+	FixtureScheduled = "scheduled"
+	FixtureLive      = "live"
+	FixtureCompleted = "completed"
+	FixturePostponed = "postponed"
+	FixtureAbandoned = "abandoned"
+)
+
+// Statuses a fixture may move to from each status. Results can be entered
+// after the match without it having been started.
+var fixtureTransitions = map[string][]string{
+	FixtureScheduled: {FixtureLive, FixtureCompleted, FixturePostponed},
+	FixturePostponed: {FixtureLive, FixtureCompleted, FixturePostponed},
+	FixtureLive:      {FixtureCompleted, FixtureAbandoned},
+}
+
+// CanTransition reports whether a fixture in status from may move to to.
+func CanTransition(from, to string) bool {
+	return slices.Contains(fixtureTransitions[from], to)
 }
 
 type Fixture struct {
 	ID                 bson.ObjectID   `bson:"_id,omitempty"`
-	Date               string          `bson:"date"`
-	HomeTeam           string          `bson:"home_team"`
-	AwayTeam           string          `bson:"away_team"`
-	HomeScore          string          `bson:"home_score"`
-	AwayScore          string          `bson:"away_score"`
+	Date               time.Time       `bson:"date"`
+	Status             string          `bson:"status"`
+	HomeTeamID         bson.ObjectID   `bson:"home_team_id"` // A Team or an Opponent
+	AwayTeamID         bson.ObjectID   `bson:"away_team_id"`
+	HomeTeam           string          `bson:"home_team,omitempty"` // Resolved from HomeTeamID
+	AwayTeam           string          `bson:"away_team,omitempty"`
+	HomeIsOpponent     bool            `bson:"home_is_opponent,omitempty"`
+	AwayIsOpponent     bool            `bson:"away_is_opponent,omitempty"`
+	SeasonID           bson.ObjectID   `bson:"season_id,omitempty"`
+	CompetitionID      bson.ObjectID   `bson:"competition_id,omitempty"`
+	HomeScore          *int            `bson:"home_score,omitempty"` // Unset until there is a result
+	AwayScore          *int            `bson:"away_score,omitempty"`
 	ManOfTheMatch      bson.ObjectID   `bson:"man_of_the_match,omitempty"`
 	Lineup             []bson.ObjectID `bson:"lineup"`
 	ManOfTheMatchName  string          `bson:"man_of_the_match_name,omitempty"`
@@ -57,7 +361,101 @@ type Fixture struct {
 	GoalScorersNames   []string        `bson:"goal_scorers_names,omitempty"`
 	AssistScorers      []bson.ObjectID `bson:"assist_scorers,omitempty"`
 	AssistScorersNames []string        `bson:"assist_scorers_names,omitempty"`
+	Events             []MatchEvent    `bson:"events,omitempty"` // Ordered by minute
 	Location           Location        `bson:"location,omitempty"`
+	TenantID           bson.ObjectID   `bson:"tenant_id,omitempty" json:"-"`
+}
+
+// Season is a club's playing year. Fixtures dated inside it belong to it.
+type Season struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	Name      string        `bson:"name"`
+	StartDate time.Time     `bson:"start_date"`
+	EndDate   time.Time     `bson:"end_date"`
+	Created   string        `bson:"created"`
+	TenantID  bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+// overlaps reports whether the season shares any time with one running from
+// start to end. Both ends are included.
+func (s Season) overlaps(start, end time.Time) bool {
+	return !s.StartDate.After(end) && !s.EndDate.Before(start)
+}
+
+const (
+	CompetitionLeague   = "league"
+	CompetitionCup      = "cup"
+	CompetitionFriendly = "friendly"
+)
+
+// Competition is a league, cup or set of friendlies fixtures are played in.
+type Competition struct {
+	ID       bson.ObjectID `bson:"_id,omitempty"`
+	Name     string        `bson:"name"`
+	Type     string        `bson:"type"`
+	Created  string        `bson:"created"`
+	TenantID bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+// Opponent is a club the fixtures are played against that isn't managed in
+// FC Tracker. It only has a name.
+type Opponent struct {
+	ID       bson.ObjectID `bson:"_id,omitempty"`
+	Name     string        `bson:"name"`
+	Created  string        `bson:"created"`
+	TenantID bson.ObjectID `bson:"tenant_id,omitempty" json:"-"`
+}
+
+const (
+	EventGoal          = "goal"
+	EventOwnGoal       = "own_goal"
+	EventPenaltyScored = "penalty_scored"
+	EventPenaltyMissed = "penalty_missed"
+	EventYellowCard    = "yellow_card"
+	EventSecondYellow  = "second_yellow"
+	EventRedCard       = "red_card"
+	EventSubstitution  = "substitution"
+
+	SideHome = "home"
+	SideAway = "away"
+)
+
+// ValidEventType reports whether kind is a known match event type.
+func ValidEventType(kind string) bool {
+	switch kind {
+	case EventGoal, EventOwnGoal, EventPenaltyScored, EventPenaltyMissed,
+		EventYellowCard, EventSecondYellow, EventRedCard, EventSubstitution:
+		return true
+	}
+	return false
+}
+
+// MatchEvent is one moment in a fixture's timeline. Side is the team the
+// player plays for. Players of opponents aren't tracked, so their events
+// have no PlayerID. Names are copied when the event is saved, like the
+// fixture's scorer names.
+type MatchEvent struct {
+	ID             bson.ObjectID `bson:"_id"`
+	Type           string        `bson:"type"`
+	Side           string        `bson:"side"`
+	Minute         int           `bson:"minute"`
+	AddedTime      int           `bson:"added_time,omitempty"` // Minutes of stoppage time, as in 90+3
+	PlayerID       bson.ObjectID `bson:"player_id,omitempty"`
+	PlayerName     string        `bson:"player_name,omitempty"`
+	AssistID       bson.ObjectID `bson:"assist_id,omitempty"` // Goals only
+	AssistName     string        `bson:"assist_name,omitempty"`
+	SubstituteID   bson.ObjectID `bson:"substitute_id,omitempty"` // Player coming on, substitutions only
+	SubstituteName string        `bson:"substitute_name,omitempty"`
+	Note           string        `bson:"note,omitempty"`
+}
+
+// ScoredBy returns the player credited with a goal from the event, if any.
+// Own goals aren't credited to the player who put the ball in.
+func (e MatchEvent) ScoredBy() (bson.ObjectID, bool) {
+	if (e.Type == EventGoal || e.Type == EventPenaltyScored) && !e.PlayerID.IsZero() {
+		return e.PlayerID, true
+	}
+	return bson.ObjectID{}, false
 }
 
 type Location struct {
@@ -66,10 +464,10 @@ type Location struct {
 }
 
 // In db/types.go or db/db.go
-func newPlayer(name, position, funFact, age string, teamId bson.ObjectID) Player {
+func newPlayer(name, position, funFact string, dob time.Time, teamId bson.ObjectID) Player {
 	return Player{
 		Name:     name,
-		Age:      age,
+		DOB:      dob,
 		Position: position,
 		FunFact:  funFact,
 
@@ -83,7 +481,7 @@ func newPlayer(name, position, funFact, age string, teamId bson.ObjectID) Player
 	}
 }
 
-func newTeam(name, coach, founded string) Team {
+func newTeam(name, coach string, founded int) Team {
 	return Team{
 		Name:    name,
 		Coach:   coach,
@@ -92,11 +490,12 @@ func newTeam(name, coach, founded string) Team {
 	}
 }
 
-func newFixture(date, homeTeam, awayTeam, homeScore, awayScore string, manOfTheMatch bson.ObjectID) Fixture {
+func newFixture(date time.Time, status string, homeTeam, awayTeam bson.ObjectID, homeScore, awayScore *int, manOfTheMatch bson.ObjectID) Fixture {
 	return Fixture{
 		Date:          date,
-		HomeTeam:      homeTeam,
-		AwayTeam:      awayTeam,
+		Status:        status,
+		HomeTeamID:    homeTeam,
+		AwayTeamID:    awayTeam,
 		HomeScore:     homeScore,
 		AwayScore:     awayScore,
 		ManOfTheMatch: manOfTheMatch,
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	defaultUserPageSize = 25
+	maxUserPageSize     = 100
+
+	// Impersonation tokens can't be refreshed, support has to ask again
+	impersonationTTL = 15 * time.Minute
+)
+
+type setRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// adminTargetUser loads the club user named in the path, writing a 404 when
+// there is none.
+func adminTargetUser(c *gin.Context) (db.User, bool) {
+	user, err := db.GetClubUserByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return db.User{}, false
+	}
+	return user, true
+}
+
+// notSelf writes a 400 and returns false when an admin targets their own
+// account, which could lock them out.
+func notSelf(c *gin.Context, user db.User) bool {
+	if user.ID.Hex() == c.GetString("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot do this to your own account"})
+		return false
+	}
+	return true
+}
+
+// listUsers pages through the club's users. q searches names and emails.
+func listUsers(c *gin.Context) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultUserPageSize)), 10, 64)
+	if err != nil || limit < 1 || limit > maxUserPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxUserPageSize)})
+		return
+	}
+
+	users, total, err := db.ListClubUsers(c.Request.Context(), c.Query("q"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func getUser(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func setUserRole(c *gin.Context) {
+	var req setRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !db.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid role is required"})
+		return
+	}
+
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	// Don't leave the club without an admin
+	if user.GetRole() == db.RoleAdmin && req.Role != db.RoleAdmin {
+		err := db.DemoteAdmin(c.Request.Context(), user.ID, req.Role)
+		if errors.Is(err, db.ErrLastAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The club needs at least one admin"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+	} else if err := db.SetUserRole(c.Request.Context(), user.ID.Hex(), req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// disableUser blocks the account. AuthMiddleware refuses it from the next
+// request and every session is ended.
+func disableUser(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok || !notSelf(c, user) {
+		return
+	}
+
+	if err := db.SetUserDisabled(c.Request.Context(), user.ID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable account"})
+		return
+	}
+	if err := db.RevokeUserRefreshTokens(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of disabled user %s: %v", user.ID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account disabled"})
+}
+
+func enableUser(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	if err := db.SetUserDisabled(c.Request.Context(), user.ID, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account enabled"})
+}
+
+// forcePasswordReset replaces the password with a random one, ends every
+// session and emails the user a reset link.
+func forcePasswordReset(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	password, _, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if err := db.SetUserPassword(c.Request.Context(), user.ID, password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if err := db.RevokeUserRefreshTokens(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions for %s after forced reset: %v", user.ID.Hex(), err)
+	}
+
+	err = sendPasswordResetEmail(c, user,
+		"An administrator of your club has reset your FC Tracker password.",
+		"Until you do, you won't be able to log in with your old password.",
+	)
+	if err != nil {
+		log.Printf("Failed to send forced reset email to %s: %v", user.ID.Hex(), err)
+	}
+
+	if err := db.RecordAudit(c.Request.Context(), db.AuditForcePasswordReset, "users", user.ID, nil); err != nil {
+		log.Printf("Failed to audit forced reset of %s: %v", user.ID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset. The user has been emailed a link to choose a new one."})
+}
+
+// impersonateUser issues a short-lived access token for the user so support
+// can see what they see. Changes made with it are audited under both the
+// user and the admin, and it can't be used to manage the account.
+func impersonateUser(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok || !notSelf(c, user) {
+		return
+	}
+
+	if user.GetRole() == db.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot be impersonated"})
+		return
+	}
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been disabled"})
+		return
+	}
+
+	claims := accessClaims(user, "")
+	claims.Impersonator = c.GetString("userID")
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(impersonationTTL))
+
+	token, err := jwtKeys.Sign(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	adminID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	err = db.RecordAudit(c.Request.Context(), db.AuditImpersonate, "users", user.ID, map[string]db.Change{
+		"impersonated_by": {To: adminID},
+	})
+	if err != nil {
+		log.Printf("Failed to audit impersonation of %s: %v", user.ID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_in": int(impersonationTTL.Seconds()),
+		"user":       userResponse(user),
+	})
+}
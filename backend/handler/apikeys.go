@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	apiKeyPrefix = "fct_"
+
+	// Characters after apiKeyPrefix kept so users can tell keys apart
+	apiKeyDisplayLength = 6
+)
+
+var errAPIKeyRevoked = errors.New("api key revoked")
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// authenticateAPIKey resolves an API key to claims for the user who owns it,
+// so the rest of the request is handled as if that user logged in.
+func authenticateAPIKey(c *gin.Context, rawKey string) (*Claims, []string, error) {
+	key, err := db.GetAPIKeyByHash(c.Request.Context(), hashToken(rawKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	if key.Revoked {
+		return nil, nil, errAPIKeyRevoked
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), key.UserID.Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := db.TouchAPIKey(c.Request.Context(), key.ID, time.Now()); err != nil {
+		log.Printf("Failed to update last use of api key %s: %v", key.ID.Hex(), err)
+	}
+
+	claims := &Claims{
+		UserID:   user.ID.Hex(),
+		Email:    user.Email,
+		Name:     user.Name,
+		Role:     user.GetRole(),
+		TenantID: user.TenantID.Hex(),
+		Verified: user.Verified,
+	}
+	return claims, key.Scopes, nil
+}
+
+// RequireScope limits API key requests to keys holding scope. Requests made
+// with a user's own login are not affected.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := c.Get("apiKeyScopes")
+		if ok && !slices.Contains(scopes.([]string), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects API keys and impersonation, for routes that manage
+// the account itself.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiKeyScopes"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an API key"})
+			c.Abort()
+			return
+		}
+		if c.GetString("impersonatorID") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used while impersonating"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func createAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Name == "" || len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and at least one scope are required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !db.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope})
+			return
+		}
+	}
+
+	token, _, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	rawKey := apiKeyPrefix + token
+
+	userID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	key, err := db.CreateAPIKey(c.Request.Context(), userID, req.Name, rawKey[:len(apiKeyPrefix)+apiKeyDisplayLength], hashToken(rawKey), req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	// The key itself is only ever shown here
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": rawKey})
+}
+
+func getAPIKeys(c *gin.Context) {
+	keys, err := db.GetUserAPIKeys(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func revokeAPIKey(c *gin.Context) {
+	if err := db.RevokeAPIKey(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAuditLog lists the club's audit log. It can be filtered by actor,
+// action, entity, entity_id and a since/until range (RFC 3339). Pass the id
+// of the last entry as before to get the next page.
+func getAuditLog(c *gin.Context) {
+	filter := db.AuditFilter{
+		ActorID:  c.Query("actor"),
+		Action:   c.Query("action"),
+		Entity:   c.Query("entity"),
+		EntityID: c.Query("entity_id"),
+		Before:   c.Query("before"),
+	}
+
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " time, use RFC 3339"})
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := db.GetAuditEntries(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
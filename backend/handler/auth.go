@@ -1,64 +1,155 @@
 package handler
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"fctracker/db"
+	"fctracker/keyring"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-var jwtSecret []byte
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var jwtKeys *keyring.Keyring
+
+// initJWTKeys builds the keyring tokens are signed with. Tokens are signed
+// with JWT_PRIVATE_KEY_FILE (RSA or Ed25519 PEM) when it is set, otherwise
+// with JWT_SECRET. To rotate, move the old secret to JWT_PREVIOUS_SECRETS or
+// the old key's public half to JWT_PUBLIC_KEY_FILES (both comma separated)
+// so existing sessions keep working.
+func initJWTKeys() {
+	var signing *keyring.Key
+	var verifyOnly []*keyring.Key
 
-func initJWTSecret() {
 	secret := os.Getenv("JWT_SECRET")
+	var secretKey *keyring.Key
+	if secret != "" {
+		secretKey = keyring.NewHMACKey([]byte(secret))
+	}
+
+	if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		pemData, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read JWT_PRIVATE_KEY_FILE: %v", err)
+		}
+		signing, err = keyring.ParsePrivateKey(pemData)
+		if err != nil {
+			log.Fatalf("Failed to parse JWT_PRIVATE_KEY_FILE: %v", err)
+		}
+		if secretKey != nil {
+			verifyOnly = append(verifyOnly, secretKey)
+		}
+	} else if secretKey != nil {
+		signing = secretKey
+	} else {
+		log.Fatalf("JWT_SECRET or JWT_PRIVATE_KEY_FILE must be set")
+	}
 
-	if secret == "" {
-		log.Fatalf("JWT_SECRET is not set")
+	for _, previous := range strings.Split(os.Getenv("JWT_PREVIOUS_SECRETS"), ",") {
+		if previous = strings.TrimSpace(previous); previous != "" {
+			verifyOnly = append(verifyOnly, keyring.NewHMACKey([]byte(previous)))
+		}
+	}
+
+	for _, path := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		pemData, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read public key %s: %v", path, err)
+		}
+		key, err := keyring.ParsePublicKey(pemData)
+		if err != nil {
+			log.Fatalf("Failed to parse public key %s: %v", path, err)
+		}
+		verifyOnly = append(verifyOnly, key)
 	}
 
-	jwtSecret = []byte(secret)
+	keys, err := keyring.New(signing, verifyOnly...)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	// Tokens issued before key IDs existed were signed with JWT_SECRET
+	if secretKey != nil {
+		keys.SetFallback(secretKey)
+	}
+	jwtKeys = keys
+
+	log.Printf("Signing tokens with %s key %s", signing.Method.Alg(), signing.ID)
+}
+
+// initBootstrapAdmin promotes the account named by ADMIN_EMAIL so a fresh
+// deployment has someone who can hand out roles.
+func initBootstrapAdmin() {
+	email := os.Getenv("ADMIN_EMAIL")
+	if email == "" {
+		return
+	}
+
+	if err := db.SetUserRoleByEmail(context.Background(), email, db.RoleAdmin); err != nil {
+		log.Printf("Warning: could not promote %s to admin: %v", email, err)
+		return
+	}
+	log.Printf("Promoted %s to admin", email)
 }
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Name   string `json:"name"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	TenantID  string `json:"tid"` // Club the user belongs to
+	Verified  bool   `json:"email_verified"`
+	SessionID string `json:"sid"` // Refresh token family the access token was issued from
+	// Admin acting as the user, only set on impersonation tokens
+	Impersonator string `json:"imp,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func generateToken(user db.User) (string, error) {
-	claims := Claims{
-		UserID: user.ID.Hex(),
-		Email:  user.Email,
-		Name:   user.Name,
+func generateToken(user db.User, sessionID string) (string, error) {
+	return jwtKeys.Sign(accessClaims(user, sessionID))
+}
+
+func accessClaims(user db.User, sessionID string) Claims {
+	return Claims{
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.GetRole(),
+		TenantID:  user.TenantID.Hex(),
+		Verified:  user.Verified,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(72 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
 }
 
 func validateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, jwtKeys.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		// Pending two-factor tokens must never work as access tokens
+		if slices.Contains(claims.Audience, mfaAudience) {
+			return nil, jwt.ErrTokenInvalidAudience
+		}
 		return claims, nil
 	}
 	return nil, jwt.ErrSignatureInvalid
@@ -66,30 +157,135 @@ func validateToken(tokenString string) (*Claims, error) {
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var token string
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		if authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+				c.Abort()
+				return
+			}
+			token = parts[1]
+		} else if cookie, err := c.Cookie(accessCookie); cookiesEnabled && err == nil && cookie != "" {
+			// Browsers in cookie mode send the token as a cookie instead
+			token = cookie
+			c.Set("cookieAuth", true)
+		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+		// Scripts send API keys in the same header as a JWT
+		var claims *Claims
+		var err error
+		if strings.HasPrefix(token, apiKeyPrefix) {
+			var scopes []string
+			claims, scopes, err = authenticateAPIKey(c, token)
+			c.Set("apiKeyScopes", scopes)
+		} else {
+			claims, err = validateToken(token)
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		claims, err := validateToken(parts[1])
+		// Disabled and deleted accounts are shut out at once, whatever tokens
+		// they still hold
+		user, err := db.GetUserByID(c.Request.Context(), claims.UserID)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
+		if user.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account has been disabled"})
+			c.Abort()
+			return
+		}
+
+		// Revoking a session has to stop its access tokens straight away, not
+		// only once they expire
+		if claims.SessionID != "" {
+			sessionID, err := bson.ObjectIDFromHex(claims.SessionID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			session, err := db.TouchSession(c.Request.Context(), sessionID, time.Now())
+			if err != nil || session.Revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+				c.Abort()
+				return
+			}
+		}
+
+		tenantID, err := bson.ObjectIDFromHex(claims.TenantID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		// Every db call made with the request context is scoped to the club
+		// and its changes are attributed to the user in the audit log
+		ctx := db.WithTenant(c.Request.Context(), tenantID)
+		ctx = db.WithActor(ctx, user.ID)
+		if adminID, err := bson.ObjectIDFromHex(claims.Impersonator); err == nil {
+			ctx = db.WithImpersonator(ctx, adminID)
+			c.Set("impersonatorID", claims.Impersonator)
+		}
+		c.Request = c.Request.WithContext(ctx)
 
 		c.Set("userID", claims.UserID)
 		c.Set("userEmail", claims.Email)
 		c.Set("userName", claims.Name)
+		// Taken from the account rather than the token, so a role change or
+		// verification applies to tokens already issued
+		c.Set("userRole", user.GetRole())
+		c.Set("tenantID", claims.TenantID)
+		c.Set("userVerified", user.Verified)
+		c.Set("sessionID", claims.SessionID)
+		c.Next()
+	}
+}
+
+// RequireRole only lets through users holding one of the given roles. It must
+// run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("userRole")
+		if role == "" {
+			role = db.RoleViewer
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+		c.Abort()
+	}
+}
+
+// RequireVerified blocks accounts without a verified email when
+// REQUIRE_VERIFIED_EMAIL is "true". It must run after AuthMiddleware.
+func RequireVerified() gin.HandlerFunc {
+	enabled := os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	return func(c *gin.Context) {
+		if enabled && !c.GetBool("userVerified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -103,6 +299,11 @@ type registerRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Name     string `json:"name"`
+	Club     string `json:"club"`      // Slug of an existing club to join
+	ClubName string `json:"club_name"` // Name of a new club to create
+	// Joins the invite's club and team. Required when registration is
+	// invite only.
+	InviteCode string `json:"invite_code"`
 }
 
 func login(c *gin.Context) {
@@ -117,82 +318,237 @@ func login(c *gin.Context) {
 		return
 	}
 
-	user, err := db.GetUserByEmail(req.Email)
+	if !checkLoginAllowed(c, req.Email) {
+		return
+	}
+
+	user, err := db.GetUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
+		recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	if !db.CheckPassword(user.Password, req.Password) {
+	if !verifyPassword(c, user, req.Password) {
+		recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	token, err := generateToken(user)
+	// Accounts with two-factor have to pass a second step
+	if user.TOTPEnabled {
+		requireSecondFactor(c, user)
+		return
+	}
+
+	recordLoginSuccess(c, user.Email)
+	respondWithTokens(c, http.StatusOK, user)
+}
+
+// requireSecondFactor answers a login with a short-lived token for the
+// two-factor step instead of a session.
+func requireSecondFactor(c *gin.Context, user db.User) {
+	mfaToken, err := generateMFAToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
-
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID.Hex(),
-			"email": user.Email,
-			"name":  user.Name,
-		},
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
 	})
 }
 
+// userResponse is how users are described to the frontend.
+func userResponse(user db.User) gin.H {
+	return gin.H{
+		"id":           user.ID.Hex(),
+		"email":        user.Email,
+		"name":         user.Name,
+		"role":         user.GetRole(),
+		"club":         user.TenantID.Hex(),
+		"verified":     user.Verified,
+		"totp_enabled": user.TOTPEnabled,
+	}
+}
+
+// respondWithTokens starts a new session for the user and writes the tokens
+// and user details.
+func respondWithTokens(c *gin.Context, status int, user db.User) {
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been disabled"})
+		return
+	}
+
+	token, refreshToken, err := issueTokens(c, user, bson.NewObjectID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	body := gin.H{
+		"expires_in": int(accessTokenTTL.Seconds()),
+		"user":       userResponse(user),
+	}
+	if !writeTokens(c, body, token, refreshToken) {
+		return
+	}
+
+	c.JSON(status, body)
+}
+
+// writeTokens puts the tokens in cookies when cookie mode is on and in the
+// response body otherwise. It writes an error and returns false on failure.
+func writeTokens(c *gin.Context, body gin.H, token, refreshToken string) bool {
+	if !cookiesEnabled {
+		body["token"] = token
+		body["refresh_token"] = refreshToken
+		return true
+	}
+
+	csrfToken, err := setAuthCookies(c, token, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return false
+	}
+	body["csrf_token"] = csrfToken
+	return true
+}
+
 func register(c *gin.Context) {
+	if registrationMode == RegistrationClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+		return
+	}
+
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
+	if req.InviteCode == "" && registrationMode == RegistrationInvite {
+		c.JSON(http.StatusForbidden, gin.H{"error": "An invite code is required to register"})
+		return
+	}
+	if req.InviteCode != "" && (req.Club != "" || req.ClubName != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An invite already picks the club to join"})
+		return
+	}
+
 	if req.Email == "" || req.Password == "" || req.Name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email, password, and name are required"})
 		return
 	}
 
-	if len(req.Password) < 6 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
+	if !checkPasswordPolicy(c, req.Password, req.Name, req.Email) {
 		return
 	}
 
-	user, err := db.CreateUser(req.Email, req.Password, req.Name)
-	if err != nil {
+	if _, err := db.GetUserByEmail(c.Request.Context(), req.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Registration failed. This email may already be in use."})
 		return
 	}
 
-	token, err := generateToken(user)
+	// Whoever creates a club administers it. Joining an existing club only
+	// gives read access until an admin grants more.
+	var club db.Club
+	var invite db.Invite
+	role := db.RoleViewer
+	if req.InviteCode != "" {
+		redeemed, err := db.RedeemInvite(c.Request.Context(), hashToken(req.InviteCode))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invite is invalid or has expired"})
+			return
+		}
+		invite = redeemed
+
+		existing, err := db.GetClubByID(c.Request.Context(), invite.TenantID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invite is invalid or has expired"})
+			return
+		}
+		club = existing
+		role = teamRoleGlobalRole[invite.Role]
+	} else if req.ClubName != "" {
+		slug := db.Slugify(req.ClubName)
+		if slug == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Club name must contain letters or numbers"})
+			return
+		}
+
+		created, err := db.CreateClub(c.Request.Context(), req.ClubName, slug)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "A club with this name already exists"})
+			return
+		}
+		club = created
+		role = db.RoleAdmin
+	} else {
+		slug := req.Club
+		if slug == "" {
+			slug = db.DefaultClubSlug()
+		}
+
+		existing, err := db.GetClubBySlug(c.Request.Context(), slug)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Club not found"})
+			return
+		}
+		club = existing
+	}
+
+	user, err := db.CreateUser(c.Request.Context(), req.Email, req.Password, req.Name, role, club.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		if !invite.ID.IsZero() {
+			if err := db.ReleaseInvite(c.Request.Context(), invite.ID); err != nil {
+				log.Printf("Failed to release invite %s: %v", invite.ID.Hex(), err)
+			}
+		}
+		if role == db.RoleAdmin && invite.ID.IsZero() {
+			if err := db.DeleteClub(c.Request.Context(), club.ID); err != nil {
+				log.Printf("Failed to clean up club %s: %v", club.Slug, err)
+			}
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Registration failed. This email may already be in use."})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID.Hex(),
-			"email": user.Email,
-			"name":  user.Name,
-		},
-	})
+	if !invite.ID.IsZero() {
+		if err := joinInvitedTeam(c, user, invite); err != nil {
+			log.Printf("Failed to add %s to team %s from invite: %v", user.ID.Hex(), invite.TeamID.Hex(), err)
+		}
+	}
+
+	if err := sendVerificationEmail(c, user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.ID.Hex(), err)
+	}
+
+	respondWithTokens(c, http.StatusCreated, user)
 }
 
+// me loads the user from the database, as the token's claims go stale when
+// the profile changes.
 func me(c *gin.Context) {
-	userID, _ := c.Get("userID")
-	email, _ := c.Get("userEmail")
-	name, _ := c.Get("userName")
+	user, err := db.GetClubUserByID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user": gin.H{
-			"id":    userID,
-			"email": email,
-			"name":  name,
-		},
-	})
+	response := gin.H{
+		"user": userResponse(user),
+	}
+	// Lets the frontend show that an admin is looking at the account
+	if impersonator := c.GetString("impersonatorID"); impersonator != "" {
+		response["impersonator"] = impersonator
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// jwks publishes the public keys tokens are signed with. It is empty when
+// only JWT_SECRET is used, as shared secrets can't be published.
+func jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": jwtKeys.JWKS()})
 }
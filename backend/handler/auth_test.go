@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A demoted admin loses access at once, not when their access token expires.
+func TestRoleChangeAppliesToIssuedTokens(t *testing.T) {
+	requireMongo(t)
+	useTestKeys(t)
+
+	user := createTestUser(t, db.RoleAdmin)
+	token, err := generateToken(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/api/admin/thing", AuthMiddleware(), RequireRole(db.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/thing", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("admin = %d, want 200", code)
+	}
+
+	ctx := db.WithTenant(context.Background(), user.TenantID)
+	if err := db.SetUserRole(ctx, user.ID.Hex(), db.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(); code != http.StatusForbidden {
+		t.Fatalf("demoted admin with an old token = %d, want 403", code)
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	accessCookie  = "fct_access"
+	refreshCookie = "fct_refresh"
+	csrfCookie    = "fct_csrf"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// Cookie mode keeps tokens out of reach of the frontend's JavaScript. It is
+// turned on with AUTH_COOKIES=true.
+var (
+	cookiesEnabled bool
+	cookieSecure   bool
+	cookieDomain   string
+	cookieSameSite http.SameSite
+)
+
+// initCookies reads the cookie settings. COOKIE_SECURE=false allows plain
+// http during local development and COOKIE_SAMESITE is lax, strict or none.
+func initCookies() {
+	cookiesEnabled = os.Getenv("AUTH_COOKIES") == "true"
+	cookieSecure = os.Getenv("COOKIE_SECURE") != "false"
+	cookieDomain = os.Getenv("COOKIE_DOMAIN")
+
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "strict":
+		cookieSameSite = http.SameSiteStrictMode
+	case "none":
+		cookieSameSite = http.SameSiteNoneMode
+	default:
+		cookieSameSite = http.SameSiteLaxMode
+	}
+}
+
+func setCookie(c *gin.Context, name, value, path string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cookieDomain,
+		MaxAge:   maxAge,
+		Secure:   cookieSecure,
+		HttpOnly: httpOnly,
+		SameSite: cookieSameSite,
+	})
+}
+
+// setAuthCookies writes the session cookies and returns the CSRF token the
+// frontend has to echo in the X-CSRF-Token header. The refresh token is only
+// sent to the auth routes that use it.
+func setAuthCookies(c *gin.Context, token, refreshToken string) (string, error) {
+	csrfToken, _, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	setCookie(c, accessCookie, token, "/api", int(accessTokenTTL.Seconds()), true)
+	setCookie(c, refreshCookie, refreshToken, "/api/auth", int(refreshTokenTTL.Seconds()), true)
+	// Readable by the frontend so it can copy it into the header
+	setCookie(c, csrfCookie, csrfToken, "/", int(refreshTokenTTL.Seconds()), false)
+
+	return csrfToken, nil
+}
+
+func clearAuthCookies(c *gin.Context) {
+	setCookie(c, accessCookie, "", "/api", -1, true)
+	setCookie(c, refreshCookie, "", "/api/auth", -1, true)
+	setCookie(c, csrfCookie, "", "/", -1, false)
+}
+
+// validCSRF checks the double-submit token: a cross-site page can make the
+// browser send our cookies but can't read one to put in the header.
+func validCSRF(c *gin.Context) bool {
+	cookie, err := c.Cookie(csrfCookie)
+	if err != nil || cookie == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(c.GetHeader(csrfHeader))) == 1
+}
+
+// RequireCSRF rejects state-changing requests authenticated by cookie that
+// don't carry the CSRF token. It must run after AuthMiddleware.
+func RequireCSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if c.GetBool("cookieAuth") && !validCSRF(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestRequireCSRF(t *testing.T) {
+	const token = "csrf-token"
+
+	tests := []struct {
+		name       string
+		method     string
+		cookieAuth bool
+		cookie     string
+		header     string
+		want       int
+	}{
+		{"bearer requests don't need a token", http.MethodPost, false, "", "", http.StatusOK},
+		{"safe methods don't need a token", http.MethodGet, true, "", "", http.StatusOK},
+		{"head is safe", http.MethodHead, true, "", "", http.StatusOK},
+		{"options is safe", http.MethodOptions, true, "", "", http.StatusOK},
+		{"matching token", http.MethodPost, true, token, token, http.StatusOK},
+		{"matching token on delete", http.MethodDelete, true, token, token, http.StatusOK},
+		{"missing header", http.MethodPost, true, token, "", http.StatusForbidden},
+		{"missing cookie", http.MethodPost, true, "", token, http.StatusForbidden},
+		{"both missing", http.MethodPut, true, "", "", http.StatusForbidden},
+		{"mismatched token", http.MethodPatch, true, token, "other-token", http.StatusForbidden},
+		{"prefix of the token", http.MethodPost, true, token, token[:4], http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Set("cookieAuth", tt.cookieAuth)
+				c.Next()
+			}, RequireCSRF())
+			r.Handle(tt.method, "/api/thing", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "/api/thing", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: csrfCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(csrfHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAuthCookies(t *testing.T) {
+	cookieSecure, cookieSameSite, cookieDomain = true, http.SameSiteLaxMode, ""
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+
+	csrfToken, err := setAuthCookies(c, "access", "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		path     string
+		httpOnly bool
+	}{
+		{accessCookie, "access", "/api", true},
+		{refreshCookie, "refresh", "/api/auth", true},
+		// The frontend has to read this one to echo it
+		{csrfCookie, csrfToken, "/", false},
+	}
+	for _, tt := range tests {
+		cookie, ok := cookies[tt.name]
+		if !ok {
+			t.Errorf("cookie %s not set", tt.name)
+			continue
+		}
+		if cookie.Value != tt.value || cookie.Path != tt.path || cookie.HttpOnly != tt.httpOnly {
+			t.Errorf("cookie %s = value %q path %q httpOnly %v, want %q %q %v",
+				tt.name, cookie.Value, cookie.Path, cookie.HttpOnly, tt.value, tt.path, tt.httpOnly)
+		}
+		if !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie %s isn't Secure and SameSite=Lax", tt.name)
+		}
+	}
+	if csrfToken == "" {
+		t.Error("empty CSRF token")
+	}
+}
+
+func TestClearAuthCookies(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+
+	clearAuthCookies(c)
+
+	cleared := 0
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge >= 0 || cookie.Value != "" {
+			t.Errorf("cookie %s isn't cleared: %+v", cookie.Name, cookie)
+		}
+		cleared++
+	}
+	if cleared != 3 {
+		t.Errorf("cleared %d cookies, want 3", cleared)
+	}
+}
+
+// The refresh cookie is sent by the browser on its own, so using it needs
+// the CSRF token as well.
+func TestRefreshTokenFromCookieNeedsCSRF(t *testing.T) {
+	defer func(enabled bool) { cookiesEnabled = enabled }(cookiesEnabled)
+	cookiesEnabled = true
+
+	tests := []struct {
+		name      string
+		body      string
+		csrf      string
+		wantOK    bool
+		wantToken string
+		wantCode  int
+	}{
+		{"body token needs no CSRF", `{"refresh_token":"from-body"}`, "", true, "from-body", http.StatusOK},
+		{"cookie with CSRF", "", "csrf", true, "from-cookie", http.StatusOK},
+		{"cookie without CSRF", "", "", false, "", http.StatusForbidden},
+		{"cookie with wrong CSRF", "", "wrong", false, "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Request.AddCookie(&http.Cookie{Name: refreshCookie, Value: "from-cookie"})
+			c.Request.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf"})
+			if tt.csrf != "" {
+				c.Request.Header.Set(csrfHeader, tt.csrf)
+			}
+
+			token, ok := refreshTokenFromRequest(c)
+			if ok != tt.wantOK || token != tt.wantToken {
+				t.Errorf("refreshTokenFromRequest = %q, %v, want %q, %v", token, ok, tt.wantToken, tt.wantOK)
+			}
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
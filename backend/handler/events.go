@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	maxMinute    = 120 // Full time after extra time
+	maxAddedTime = 30
+	maxNoteLen   = 280
+)
+
+type fixtureEventRequest struct {
+	Type         string `json:"type"`
+	Side         string `json:"side"` // home or away
+	Minute       int    `json:"minute"`
+	AddedTime    int    `json:"added_time"`
+	PlayerID     string `json:"player_id"`
+	AssistID     string `json:"assist_id"`     // Goals only
+	SubstituteID string `json:"substitute_id"` // Player coming on, substitutions only
+	Note         string `json:"note"`
+}
+
+func getFixtureEvents(c *gin.Context) {
+	fixture, err := db.GetFixtureByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return
+	}
+
+	events := fixture.Events
+	if events == nil {
+		events = []db.MatchEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+func addFixtureEvent(c *gin.Context) {
+	id := c.Param("id")
+	event, ok := bindFixtureEvent(c, id)
+	if !ok {
+		return
+	}
+
+	event, err := db.AddFixtureEvent(c.Request.Context(), id, event)
+	if err != nil {
+		c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"event": event})
+}
+
+func updateFixtureEvent(c *gin.Context) {
+	id := c.Param("id")
+	event, ok := bindFixtureEvent(c, id)
+	if !ok {
+		return
+	}
+
+	event, err := db.UpdateFixtureEvent(c.Request.Context(), id, c.Param("eventId"), event)
+	if err != nil {
+		c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event": event})
+}
+
+func deleteFixtureEvent(c *gin.Context) {
+	id := c.Param("id")
+	if !requireFixtureManagerByID(c, id) || !requireFixtureInPlay(c, id) {
+		return
+	}
+
+	if err := db.DeleteFixtureEvent(c.Request.Context(), id, c.Param("eventId")); err != nil {
+		c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event deleted"})
+}
+
+func eventErrorStatus(err error) int {
+	if errors.Is(err, db.ErrEventNotFound) || errors.Is(err, db.ErrFixtureNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusConflict
+}
+
+// bindFixtureEvent checks the caller may edit the fixture's timeline and
+// turns the request body into an event, writing an error response when it
+// can't.
+func bindFixtureEvent(c *gin.Context, fixtureID string) (db.MatchEvent, bool) {
+	if !requireFixtureManagerByID(c, fixtureID) || !requireFixtureInPlay(c, fixtureID) {
+		return db.MatchEvent{}, false
+	}
+
+	var req fixtureEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return db.MatchEvent{}, false
+	}
+
+	fixture, err := db.GetFixtureByID(c.Request.Context(), fixtureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return db.MatchEvent{}, false
+	}
+
+	event, msg := fixtureEvent(c, fixture, req)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return db.MatchEvent{}, false
+	}
+	return event, true
+}
+
+// fixtureEvent validates a request against the fixture, returning a message
+// for the caller when it isn't a possible event.
+func fixtureEvent(c *gin.Context, fixture db.Fixture, req fixtureEventRequest) (db.MatchEvent, string) {
+	event := db.MatchEvent{
+		Type:      req.Type,
+		Side:      req.Side,
+		Minute:    req.Minute,
+		AddedTime: req.AddedTime,
+		Note:      strings.TrimSpace(req.Note),
+	}
+
+	if !db.ValidEventType(event.Type) {
+		return event, "Unknown event type"
+	}
+	if event.Minute < 1 || event.Minute > maxMinute {
+		return event, "Minute must be between 1 and 120"
+	}
+	if event.AddedTime < 0 || event.AddedTime > maxAddedTime {
+		return event, "Added time must be between 0 and 30 minutes"
+	}
+	if len(event.Note) > maxNoteLen {
+		return event, "Note is too long"
+	}
+
+	var teamID bson.ObjectID
+	var opponent bool
+	switch event.Side {
+	case db.SideHome:
+		teamID, opponent = fixture.HomeTeamID, fixture.HomeIsOpponent
+	case db.SideAway:
+		teamID, opponent = fixture.AwayTeamID, fixture.AwayIsOpponent
+	default:
+		return event, "Side must be home or away"
+	}
+
+	if event.Type != db.EventGoal && req.AssistID != "" {
+		return event, "Only goals can have an assist"
+	}
+	if event.Type != db.EventSubstitution && req.SubstituteID != "" {
+		return event, "Only substitutions can have a substitute"
+	}
+
+	// Opponents' players aren't tracked, so their events are just a team and a minute
+	if opponent {
+		if req.PlayerID != "" || req.AssistID != "" || req.SubstituteID != "" {
+			return event, "Players can't be named for an opponent"
+		}
+		return event, ""
+	}
+
+	if req.PlayerID == "" {
+		return event, "A player is required"
+	}
+	if event.Type == db.EventSubstitution && req.SubstituteID == "" {
+		return event, "A substitute is required"
+	}
+
+	ids := []struct {
+		value string
+		id    *bson.ObjectID
+	}{
+		{req.PlayerID, &event.PlayerID},
+		{req.AssistID, &event.AssistID},
+		{req.SubstituteID, &event.SubstituteID},
+	}
+	for _, p := range ids {
+		if p.value == "" {
+			continue
+		}
+		player, err := db.GetPlayerByID(c.Request.Context(), p.value)
+		if err != nil {
+			return event, "Player not found"
+		}
+		if player.TeamID != teamID {
+			return event, player.Name + " doesn't play for the " + event.Side + " team"
+		}
+		*p.id = player.ID
+	}
+
+	if event.AssistID == event.PlayerID || event.SubstituteID == event.PlayerID {
+		return event, "A player can't assist their own goal or replace themselves"
+	}
+	return event, ""
+}
+
+// requireNoTimeline writes a 409 and returns false when the fixture has a
+// timeline, as its scorers are then kept in step with the events.
+func requireNoTimeline(c *gin.Context, fixtureID string) bool {
+	fixture, err := db.GetFixtureByID(c.Request.Context(), fixtureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return false
+	}
+	if len(fixture.Events) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This fixture has a timeline, add a goal event instead"})
+		return false
+	}
+	return true
+}
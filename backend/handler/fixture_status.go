@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"net/http"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type finishFixtureRequest struct {
+	HomeScore     *int   `json:"home_score"`
+	AwayScore     *int   `json:"away_score"`
+	ManOfTheMatch string `json:"man_of_the_match"`
+}
+
+type manOfTheMatchRequest struct {
+	ManOfTheMatch string `json:"man_of_the_match"` // Empty clears it
+}
+
+type postponeFixtureRequest struct {
+	Date string `json:"date"` // New date, if one is known
+}
+
+// setFixtureStatus applies a status change on behalf of a fixture manager.
+func setFixtureStatus(c *gin.Context, status string, set bson.M) {
+	id := c.Param("id")
+	if !requireFixtureManagerByID(c, id) {
+		return
+	}
+
+	fixture, err := db.SetFixtureStatus(c.Request.Context(), id, status, set)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fixture": fixture})
+}
+
+func startFixture(c *gin.Context) {
+	setFixtureStatus(c, db.FixtureLive, nil)
+}
+
+// finishFixture records the result. Scores are required unless the fixture
+// has a timeline, which then decides the score. Man of the match can be left
+// out and picked later with setManOfTheMatch.
+func finishFixture(c *gin.Context) {
+	var req finishFixtureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	fixture, err := db.GetFixtureByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return
+	}
+	if len(fixture.Events) > 0 {
+		home, away := db.TimelineScore(fixture.Events)
+		if (req.HomeScore != nil && *req.HomeScore != home) || (req.AwayScore != nil && *req.AwayScore != away) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The score doesn't match the fixture's goal events"})
+			return
+		}
+		req.HomeScore, req.AwayScore = &home, &away
+	}
+
+	if req.HomeScore == nil || req.AwayScore == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Home and away scores are required"})
+		return
+	}
+	for _, score := range []int{*req.HomeScore, *req.AwayScore} {
+		if score < 0 || score > maxScore {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Scores must be between 0 and 99"})
+			return
+		}
+	}
+
+	set := bson.M{"home_score": req.HomeScore, "away_score": req.AwayScore}
+	if req.ManOfTheMatch != "" {
+		player, err := db.GetPlayerByID(c.Request.Context(), req.ManOfTheMatch)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Man of the match must be one of the club's players"})
+			return
+		}
+		set["man_of_the_match"] = player.ID
+	}
+
+	setFixtureStatus(c, db.FixtureCompleted, set)
+}
+
+// setManOfTheMatch picks or changes the man of the match of a live or
+// completed fixture.
+func setManOfTheMatch(c *gin.Context) {
+	id := c.Param("id")
+	if !requireFixtureManagerByID(c, id) {
+		return
+	}
+
+	var req manOfTheMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var playerID bson.ObjectID
+	if req.ManOfTheMatch != "" {
+		player, err := db.GetPlayerByID(c.Request.Context(), req.ManOfTheMatch)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Man of the match must be one of the club's players"})
+			return
+		}
+		playerID = player.ID
+	}
+
+	fixture, err := db.SetManOfTheMatch(c.Request.Context(), id, playerID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fixture": fixture})
+}
+
+func postponeFixture(c *gin.Context) {
+	var req postponeFixtureRequest
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	var set bson.M
+	if req.Date != "" {
+		date, err := parseDate(req.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		set = bson.M{"date": date}
+		// Moving a match can move it into another season
+		if season, err := db.GetSeasonOn(c.Request.Context(), date); err == nil {
+			set["season_id"] = season.ID
+		}
+	}
+	setFixtureStatus(c, db.FixturePostponed, set)
+}
+
+func abandonFixture(c *gin.Context) {
+	setFixtureStatus(c, db.FixtureAbandoned, nil)
+}
+
+// requireFixtureInPlay writes a 409 and returns false unless the fixture
+// has kicked off, as stats can't be added to a match that hasn't been played.
+func requireFixtureInPlay(c *gin.Context, fixtureID string) bool {
+	fixture, err := db.GetFixtureByID(c.Request.Context(), fixtureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return false
+	}
+	if fixture.Status != db.FixtureLive && fixture.Status != db.FixtureCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stats can only be added to live or completed fixtures"})
+		return false
+	}
+	return true
+}
@@ -2,15 +2,18 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"fctracker/db"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 // DB initalisation
 func seed(c *gin.Context) {
-	err := db.SeedPlayers()
+	err := db.SeedPlayers(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"mesage": "error seeding players", "error": err.Error()})
 		return
@@ -20,7 +23,7 @@ func seed(c *gin.Context) {
 
 // PLayer Handlers
 func getActivePlayers(c *gin.Context) {
-	players, err := db.GetActivePlayers()
+	players, err := db.GetActivePlayers(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"mesage": "error getting active players", "error": "no players found"})
 		return
@@ -36,7 +39,7 @@ func getActivePlayers(c *gin.Context) {
 
 func getPlayerByID(c *gin.Context) {
 	id := c.Param("id")
-	player, err := db.GetPlayerByID(id)
+	player, err := db.GetPlayerByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
 		return
@@ -45,8 +48,13 @@ func getPlayerByID(c *gin.Context) {
 }
 
 func getPlayerFixtures(c *gin.Context) {
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
-	fixtures, err := db.GetPlayerFixtures(id)
+	fixtures, err := db.GetPlayerFixtures(c.Request.Context(), id, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -56,19 +64,32 @@ func getPlayerFixtures(c *gin.Context) {
 
 func addPlayer(c *gin.Context) {
 	name := c.Query("name")
-	age := c.Query("age")
 	position := c.Query("position")
 	fact := c.Query("fact")
 	teamName := c.Query("teamName")
 
+	var dob time.Time
+	if value := c.Query("dob"); value != "" {
+		parsed, err := parseDOB(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dob = parsed
+	}
+
 	// Get Team ID from Team Name
-	team, err := db.GetTeamByName(teamName)
+	team, err := db.GetTeamByName(c.Request.Context(), teamName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error finding team", "error": err.Error()})
 		return
 	}
 
-	response, err := db.AddPlayer(name, position, fact, age, team.ID.Hex())
+	if !requireTeamManager(c, team.ID) {
+		return
+	}
+
+	response, err := db.AddPlayer(c.Request.Context(), name, position, fact, dob, team.ID.Hex())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"mesage": "error adding player", "error": err.Error()})
 		return
@@ -89,26 +110,39 @@ func updatePlayer(c *gin.Context) {
 	params := c.Request.URL.Query()
 	update := make(map[string]any)
 
-	// List of updatable fields
-	allowed := map[string]bool{
-		"name":             true,
-		"age":              true,
-		"position":         true,
-		"fun_fact":         true,
-		"goals":            true,
-		"assists":          true,
-		"games_played":     true,
-		"man_of_the_match": true,
-		"active":           true,
+	// Updatable fields and how to turn their values into stored types
+	allowed := map[string]func(string) (any, error){
+		"name":         text,
+		"dob":          func(v string) (any, error) { return parseDOB(v) },
+		"position":     text,
+		"fun_fact":     text,
+		"games_played": func(v string) (any, error) { return parseCount(v) },
+		"active":       func(v string) (any, error) { return strconv.ParseBool(v) },
 	}
 
+	// Goals, assists and man of the match awards are counted from completed
+	// fixtures by db.RecountPlayerStats, which would overwrite anything set
+	// here. Record them on the fixture instead.
+	derived := map[string]bool{"goals": true, "assists": true, "man_of_the_match": true}
+
 	for key, values := range params {
 		if key == "id" {
 			continue // don't update the id
 		}
-		if allowed[key] && len(values) > 0 {
-			update[key] = values[0]
+		if derived[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"mesage": "error updating player", "error": key + " is counted from fixtures and can't be set"})
+			return
+		}
+		parse, ok := allowed[key]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		value, err := parse(values[0])
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"mesage": "error updating player", "error": "invalid " + key + ": " + err.Error()})
+			return
 		}
+		update[key] = value
 	}
 
 	if len(update) == 0 {
@@ -116,8 +150,18 @@ func updatePlayer(c *gin.Context) {
 		return
 	}
 
+	player, err := db.GetPlayerByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
+		return
+	}
+
+	if !requireTeamManager(c, player.TeamID) {
+		return
+	}
+
 	// Call a db function to update the player
-	result, err := db.UpdatePlayerByID(id, update)
+	result, err := db.UpdatePlayerByID(c.Request.Context(), id, update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error updating player", "error": err.Error()})
 		return
@@ -129,7 +173,17 @@ func updatePlayer(c *gin.Context) {
 func deletePlayer(c *gin.Context) {
 	id := c.Query("id")
 
-	err := db.DeletePlayer(id)
+	player, err := db.GetPlayerByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
+		return
+	}
+
+	if !requireTeamManager(c, player.TeamID) {
+		return
+	}
+
+	err = db.DeletePlayer(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error deleting player", "error": err.Error()})
 		return
@@ -143,9 +197,22 @@ func deletePlayer(c *gin.Context) {
 func addTeam(c *gin.Context) {
 	name := c.Query("name")
 	coach := c.Query("coach")
-	founded := c.Query("founded")
 
-	team, err := db.AddTeam(name, coach, founded)
+	founded, err := parseFounded(c.Query("founded"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	team, err := db.AddTeam(c.Request.Context(), name, coach, founded)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "error adding team", "error": err.Error()})
+		return
+	}
+
+	// Whoever creates the team coaches it
+	userID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	_, err = db.AddMembership(c.Request.Context(), userID, team.ID, db.TeamRoleCoach, db.MembershipActive, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error adding team", "error": err.Error()})
 		return
@@ -157,7 +224,7 @@ func addTeam(c *gin.Context) {
 func getTeamById(c *gin.Context) {
 	id := c.Query("id")
 
-	team, err := db.GetTeamById(id)
+	team, err := db.GetTeamById(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error getting team", "error": err.Error()})
 		return
@@ -169,7 +236,7 @@ func getTeamById(c *gin.Context) {
 func getTeamIdByName(c *gin.Context) {
 	name := c.Query("name")
 
-	team, err := db.GetTeamByName(name)
+	team, err := db.GetTeamByName(c.Request.Context(), name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error getting team", "error": err.Error()})
 		return
@@ -179,7 +246,7 @@ func getTeamIdByName(c *gin.Context) {
 }
 
 func getAllTeams(c *gin.Context) {
-	teams, err := db.GetAllTeams()
+	teams, err := db.GetAllTeams(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"mesage": "error getting teams", "error": "no teams found"})
 		return
@@ -194,16 +261,62 @@ func getAllTeams(c *gin.Context) {
 }
 
 func addFixture(c *gin.Context) {
-	date := c.Query("date")
-	homeTeam := c.Query("homeTeam")
-	awayTeam := c.Query("awayTeam")
-	homeScore := c.Query("homeScore")
-	awayScore := c.Query("awayScore")
 	manOfMatch := c.Query("manOfTheMatch")
 	latitude := c.Query("latitude")
 	longitude := c.Query("longitude")
 
-	fixture, err := db.AddFixture(date, homeTeam, awayTeam, homeScore, awayScore, manOfMatch, latitude, longitude)
+	date, err := parseDate(c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	homeScore, err := parseScore(c.Query("homeScore"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	awayScore, err := parseScore(c.Query("awayScore"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Opponents have to be added first, so a typo can't create a new club
+	homeTeam, homeIsTeam, err := fixtureSide(c, c.Query("homeTeamId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid home team: " + err.Error()})
+		return
+	}
+	awayTeam, awayIsTeam, err := fixtureSide(c, c.Query("awayTeamId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid away team: " + err.Error()})
+		return
+	}
+	if homeTeam == awayTeam {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A team can't play itself"})
+		return
+	}
+	if !homeIsTeam && !awayIsTeam {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One side of a fixture must be one of the club's teams"})
+		return
+	}
+
+	if !requireFixtureManager(c, homeTeam, awayTeam) {
+		return
+	}
+
+	seasonID, competitionID, ok := seasonAndCompetition(c)
+	if !ok {
+		return
+	}
+	// Fixtures fall in the season covering their date unless told otherwise
+	if seasonID.IsZero() {
+		if season, err := db.GetSeasonOn(c.Request.Context(), date); err == nil {
+			seasonID = season.ID
+		}
+	}
+
+	fixture, err := db.AddFixture(c.Request.Context(), date, homeTeam, awayTeam, homeScore, awayScore, manOfMatch, latitude, longitude, seasonID, competitionID)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"mesage": "error adding fixture", "error": err.Error()})
 		return
@@ -214,7 +327,12 @@ func addFixture(c *gin.Context) {
 }
 
 func getFixtures(c *gin.Context) {
-	fixtures, err := db.GetFixtures()
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	fixtures, err := db.GetFixtures(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"mesage": "error getting fixtures", "error": err.Error()})
 		return
@@ -225,7 +343,7 @@ func getFixtures(c *gin.Context) {
 
 func getFixtureByID(c *gin.Context) {
 	id := c.Param("id")
-	fixture, err := db.GetFixtureByID(id)
+	fixture, err := db.GetFixtureByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
 		return
@@ -234,7 +352,12 @@ func getFixtureByID(c *gin.Context) {
 }
 
 func leaderboardGoals(c *gin.Context) {
-	players, err := db.GetLeaderboard("goals")
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	players, err := db.GetLeaderboard(c.Request.Context(), "goals", filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -243,7 +366,12 @@ func leaderboardGoals(c *gin.Context) {
 }
 
 func leaderboardAssists(c *gin.Context) {
-	players, err := db.GetLeaderboard("assists")
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	players, err := db.GetLeaderboard(c.Request.Context(), "assists", filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -252,7 +380,12 @@ func leaderboardAssists(c *gin.Context) {
 }
 
 func leaderboardMotm(c *gin.Context) {
-	players, err := db.GetLeaderboard("man_of_the_match")
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	players, err := db.GetLeaderboard(c.Request.Context(), "man_of_the_match", filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -261,7 +394,12 @@ func leaderboardMotm(c *gin.Context) {
 }
 
 func leaderboardFixtures(c *gin.Context) {
-	fixtures, err := db.GetLeaderboardFixtures()
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	fixtures, err := db.GetLeaderboardFixtures(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -278,7 +416,11 @@ func addGoalscorerToFixture(c *gin.Context) {
 		return
 	}
 
-	updated, err := db.AddGoalscorerToFixture(fixtureID, playerID)
+	if !requireFixtureManagerByID(c, fixtureID) || !requireFixtureInPlay(c, fixtureID) || !requireNoTimeline(c, fixtureID) {
+		return
+	}
+
+	updated, err := db.AddGoalscorerToFixture(c.Request.Context(), fixtureID, playerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -296,7 +438,11 @@ func addAssistToFixture(c *gin.Context) {
 		return
 	}
 
-	updated, err := db.AddStatToFixture(fixtureID, playerID, "assist_scorers")
+	if !requireFixtureManagerByID(c, fixtureID) || !requireFixtureInPlay(c, fixtureID) || !requireNoTimeline(c, fixtureID) {
+		return
+	}
+
+	updated, err := db.AddStatToFixture(c.Request.Context(), fixtureID, playerID, "assist_scorers")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -315,6 +461,10 @@ func addStatToFixture(c *gin.Context) {
 		return
 	}
 
+	if !requireFixtureManagerByID(c, fixtureID) || !requireFixtureInPlay(c, fixtureID) || !requireNoTimeline(c, fixtureID) {
+		return
+	}
+
 	switch stat {
 	case "goal":
 		stat = "goal_scorers"
@@ -325,7 +475,7 @@ func addStatToFixture(c *gin.Context) {
 		return
 	}
 
-	updated, err := db.AddStatToFixture(fixtureID, playerID, stat)
+	updated, err := db.AddStatToFixture(c.Request.Context(), fixtureID, playerID, stat)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
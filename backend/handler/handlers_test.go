@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Counts taken from fixtures would be overwritten by the next recount, so
+// they can't be set by hand.
+func TestUpdatePlayerRejectsCountedStats(t *testing.T) {
+	r := gin.New()
+	r.POST("/api/player/update", updatePlayer)
+
+	for _, field := range []string{"goals", "assists", "man_of_the_match"} {
+		w, resp := postJSON(t, r, "/api/player/update?id=0123456789abcdef01234567&"+field+"=3", nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("setting %s = %d %v, want 400", field, w.Code, resp)
+		}
+	}
+}
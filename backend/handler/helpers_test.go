@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"fctracker/db"
+	"fctracker/keyring"
+	"fctracker/passwords"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var connectOnce sync.Once
+
+// requireMongo connects to the server in MONGODB_TEST_URI and skips the test
+// when it isn't set. Point it at a throwaway server, the tests write to the
+// fctracker database.
+func requireMongo(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	connectOnce.Do(func() {
+		os.Setenv("MONGODB_URI", uri)
+		db.Connect()
+
+		// Real hashing costs would make every test user take a while
+		hasher, err := passwords.NewHasher(passwords.Params{
+			Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+		})
+		if err != nil {
+			panic(err)
+		}
+		db.SetPasswordHasher(hasher)
+	})
+}
+
+// useTestKeys signs tokens with a throwaway HMAC key for the test.
+func useTestKeys(t *testing.T) {
+	t.Helper()
+	keys, err := keyring.New(keyring.NewHMACKey([]byte("test-secret-" + bson.NewObjectID().Hex())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := jwtKeys
+	jwtKeys = keys
+	t.Cleanup(func() { jwtKeys = previous })
+}
+
+// createTestUser adds a user to a new club.
+func createTestUser(t *testing.T, role string) db.User {
+	t.Helper()
+	email := "test-" + bson.NewObjectID().Hex() + "@example.com"
+	user, err := db.CreateUser(context.Background(), email, "kettle-violin-42", "Test User", role, bson.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DeleteUser(db.WithTenant(context.Background(), user.TenantID), user.ID) })
+	return user
+}
+
+// postJSON sends body as JSON to the router and decodes the JSON response.
+func postJSON(t *testing.T, r http.Handler, path string, body any) (*httptest.ResponseRecorder, gin.H) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp gin.H
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("response isn't JSON: %s", w.Body)
+		}
+	}
+	return w, resp
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite"
+	RegistrationClosed = "closed"
+
+	defaultInviteTTL = 7 * 24 * time.Hour
+	maxInviteTTL     = 30 * 24 * time.Hour
+	maxInviteUses    = 100
+)
+
+var registrationMode = RegistrationOpen
+
+// initRegistration reads REGISTRATION_MODE. With invite only, accounts can
+// only be created with an invite code from a coach. Closed stops sign ups
+// altogether, so admins have to bring people in some other way.
+func initRegistration() {
+	mode := os.Getenv("REGISTRATION_MODE")
+	switch mode {
+	case "":
+		return
+	case RegistrationOpen, RegistrationInvite, RegistrationClosed:
+		registrationMode = mode
+	default:
+		log.Fatalf("REGISTRATION_MODE must be %s, %s or %s", RegistrationOpen, RegistrationInvite, RegistrationClosed)
+	}
+	log.Printf("Registration is %s", registrationMode)
+}
+
+func registrationModeDescription() string {
+	if registrationMode == RegistrationInvite {
+		return "by invite only"
+	}
+	return registrationMode
+}
+
+type createInviteCodeRequest struct {
+	Role           string `json:"role"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+	MaxUses        int    `json:"max_uses"`
+}
+
+type inviteCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// registration tells the frontend whether to show the sign up form and
+// whether it needs an invite code.
+func registration(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"mode": registrationMode})
+}
+
+// createInviteCode lets a coach hand out a code, or a link holding it, that
+// registers people straight into the team. Parents get read access.
+func createInviteCode(c *gin.Context) {
+	teamID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team id"})
+		return
+	}
+
+	var req createInviteCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if _, ok := teamRoleGlobalRole[req.Role]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A role of coach, assistant, player or parent is required"})
+		return
+	}
+
+	ttl := defaultInviteTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+		if ttl <= 0 || ttl > maxInviteTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invites can last between 1 hour and 30 days"})
+			return
+		}
+	}
+
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 1 || req.MaxUses > maxInviteUses {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An invite can be used between 1 and 100 times"})
+		return
+	}
+
+	team, err := db.GetTeamById(c.Request.Context(), teamID.Hex())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+	if !requireHeadCoach(c, teamID) {
+		return
+	}
+
+	code, codeHash, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	createdBy, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	invite, err := db.CreateInvite(c.Request.Context(), teamID, req.Role, codeHash, req.MaxUses, createdBy, time.Now().Add(ttl))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	invite.TeamName = team.Name
+
+	// The code is only ever shown here
+	c.JSON(http.StatusCreated, gin.H{
+		"invite": invite,
+		"code":   code,
+		"link":   frontendURL() + "/register?invite=" + code,
+	})
+}
+
+func getInviteCodes(c *gin.Context) {
+	teamID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team id"})
+		return
+	}
+	if !requireHeadCoach(c, teamID) {
+		return
+	}
+
+	invites, err := db.GetTeamInvites(c.Request.Context(), teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+func revokeInviteCode(c *gin.Context) {
+	teamID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team id"})
+		return
+	}
+	if !requireHeadCoach(c, teamID) {
+		return
+	}
+
+	if err := db.RevokeInvite(c.Request.Context(), teamID, c.Param("inviteId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+// checkInviteCode shows what an invite is for before someone registers with
+// it. The code is posted rather than put in the URL to keep it out of logs.
+func checkInviteCode(c *gin.Context) {
+	var req inviteCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invite code is required"})
+		return
+	}
+
+	invite, err := db.GetInviteByCode(c.Request.Context(), hashToken(req.Code))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite is invalid or has expired"})
+		return
+	}
+
+	club, err := db.GetClubByID(c.Request.Context(), invite.TenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite is invalid or has expired"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"club":       club.Name,
+		"team":       invite.TeamName,
+		"role":       invite.Role,
+		"expires_at": invite.ExpiresAt,
+	})
+}
+
+// joinInvitedTeam adds a newly registered user to the invite's team.
+func joinInvitedTeam(c *gin.Context, user db.User, invite db.Invite) error {
+	ctx := db.WithActor(db.WithTenant(c.Request.Context(), invite.TenantID), user.ID)
+	_, err := db.AddMembership(ctx, user.ID, invite.TeamID, invite.Role, db.MembershipActive, invite.CreatedBy)
+	return err
+}
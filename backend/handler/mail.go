@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"fctracker/mailer"
+)
+
+var mail mailer.Mailer
+
+func initMailer() {
+	mail = mailer.New()
+}
+
+// frontendURL is where links in emails point to.
+func frontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://localhost:5173"
+}
+
+// sendMail delivers in the background so response times don't reveal
+// whether an account exists.
+func sendMail(to, subject, body string) {
+	go func() {
+		if err := mail.Send(to, subject, body); err != nil {
+			log.Printf("Failed to send %q to %s: %v", subject, to, err)
+		}
+	}()
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"net/http"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Global role a user needs to act on a team role, see acceptInvite.
+var teamRoleGlobalRole = map[string]string{
+	db.TeamRoleCoach:     db.RoleCoach,
+	db.TeamRoleAssistant: db.RoleCoach,
+	db.TeamRolePlayer:    db.RolePlayer,
+	db.TeamRoleParent:    db.RoleViewer,
+}
+
+var roleRank = map[string]int{
+	db.RoleViewer: 0,
+	db.RolePlayer: 1,
+	db.RoleCoach:  2,
+	db.RoleAdmin:  3,
+}
+
+type inviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// canManageTeam reports whether the current user may change data belonging
+// to the team. Admins can manage every team.
+func canManageTeam(c *gin.Context, teamID bson.ObjectID) bool {
+	if c.GetString("userRole") == db.RoleAdmin {
+		return true
+	}
+
+	membership, err := db.GetMembership(c.Request.Context(), c.GetString("userID"), teamID.Hex())
+	if err != nil {
+		return false
+	}
+	return membership.CanManage()
+}
+
+// requireTeamManager writes a 403 and returns false when the current user
+// cannot manage the team.
+func requireTeamManager(c *gin.Context, teamID bson.ObjectID) bool {
+	if canManageTeam(c, teamID) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not manage this team"})
+	return false
+}
+
+// requireFixtureManager allows changes to a fixture when the current user
+// manages the home or the away team.
+func requireFixtureManager(c *gin.Context, homeTeam, awayTeam bson.ObjectID) bool {
+	for _, teamID := range []bson.ObjectID{homeTeam, awayTeam} {
+		if canManageTeam(c, teamID) {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not manage either team in this fixture"})
+	return false
+}
+
+// requireHeadCoach writes a 403 and returns false unless the current user is
+// an admin or the team's coach. Only they bring people into a team.
+func requireHeadCoach(c *gin.Context, teamID bson.ObjectID) bool {
+	if c.GetString("userRole") == db.RoleAdmin {
+		return true
+	}
+	membership, err := db.GetMembership(c.Request.Context(), c.GetString("userID"), teamID.Hex())
+	if err != nil || membership.Status != db.MembershipActive || membership.Role != db.TeamRoleCoach {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the team's coach can invite members"})
+		return false
+	}
+	return true
+}
+
+func requireFixtureManagerByID(c *gin.Context, fixtureID string) bool {
+	fixture, err := db.GetFixtureByID(c.Request.Context(), fixtureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return false
+	}
+	return requireFixtureManager(c, fixture.HomeTeamID, fixture.AwayTeamID)
+}
+
+func inviteToTeam(c *gin.Context) {
+	teamID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team id"})
+		return
+	}
+
+	var req inviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if _, ok := teamRoleGlobalRole[req.Role]; !ok || req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email and a role of coach, assistant, player or parent are required"})
+		return
+	}
+
+	if _, err := db.GetTeamById(c.Request.Context(), teamID.Hex()); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	if !requireHeadCoach(c, teamID) {
+		return
+	}
+
+	invitee, err := db.GetClubUserByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No account exists for this email"})
+		return
+	}
+
+	inviterID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	membership, err := db.AddMembership(c.Request.Context(), invitee.ID, teamID, req.Role, db.MembershipInvited, inviterID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"membership": membership})
+}
+
+func acceptInvite(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	membership, err := db.AcceptMembership(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	// Joining a team as staff or player needs the matching global role to
+	// get past RequireRole. Never demote anyone here.
+	wanted := teamRoleGlobalRole[membership.Role]
+	if roleRank[wanted] > roleRank[c.GetString("userRole")] {
+		if err := db.SetUserRole(c.Request.Context(), userID, wanted); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"membership": membership, "message": "Invite accepted"})
+}
+
+func declineInvite(c *gin.Context) {
+	membership, err := db.GetMembership(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil || membership.Status != db.MembershipInvited {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	if err := db.RemoveMembership(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite declined"})
+}
+
+func getMyInvites(c *gin.Context) {
+	invites, err := db.GetUserMemberships(c.Request.Context(), c.GetString("userID"), db.MembershipInvited)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invites"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+func getTeamMembers(c *gin.Context) {
+	teamID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team id"})
+		return
+	}
+
+	if !requireTeamManager(c, teamID) {
+		return
+	}
+
+	members, err := db.GetTeamMembers(c.Request.Context(), teamID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
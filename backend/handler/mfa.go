@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"fctracker/db"
+	"fctracker/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mfaAudience       = "mfa"
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "FC Tracker"
+)
+
+type totpCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type totpDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// generateMFAToken issues the token that proves the password step passed.
+// Its audience keeps validateToken from accepting it as an access token.
+func generateMFAToken(user db.User) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   user.ID.Hex(),
+		Audience:  jwt.ClaimStrings{mfaAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	return jwtKeys.Sign(claims)
+}
+
+func validateMFAToken(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, jwtKeys.Keyfunc, jwt.WithAudience(mfaAudience))
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+	return code[:5] + "-" + code[5:10], nil
+}
+
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(code)), "-", ""))
+}
+
+func setupTOTP(c *gin.Context) {
+	user, err := db.GetUserByID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	if err := db.SetUserTOTPSecret(c.Request.Context(), user.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(totpIssuer, user.Email, secret),
+	})
+}
+
+func confirmTOTP(c *gin.Context) {
+	var req totpCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code is required"})
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := db.EnableUserTOTP(c.Request.Context(), user.ID, step, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	// Recovery codes are only ever shown here
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func disableTOTP(c *gin.Context) {
+	var req totpDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password and code are required"})
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !verifyPassword(c, user, req.Password) || !checkSecondFactor(c, user, req.Code, "") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password or code"})
+		return
+	}
+
+	if err := db.DisableUserTOTP(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both can only be used once.
+func checkSecondFactor(c *gin.Context, user db.User, code, recoveryCode string) bool {
+	if recoveryCode != "" {
+		return db.UseRecoveryCode(c.Request.Context(), user.ID, hashRecoveryCode(recoveryCode)) == nil
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false
+	}
+	return db.ClaimTOTPStep(c.Request.Context(), user.ID, step) == nil
+}
+
+func loginTOTP(c *gin.Context) {
+	var req mfaLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA token and a code or recovery code are required"})
+		return
+	}
+
+	userID, err := validateMFAToken(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login session expired, please sign in again"})
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login session expired, please sign in again"})
+		return
+	}
+
+	if !checkLoginAllowed(c, user.Email) {
+		return
+	}
+
+	if !checkSecondFactor(c, user, req.Code, req.RecoveryCode) {
+		recordLoginFailure(c, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	recordLoginSuccess(c, user.Email)
+	respondWithTokens(c, http.StatusOK, user)
+}
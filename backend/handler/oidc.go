@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// How long the user has to finish logging in at the provider
+	oidcLoginTTL = 10 * time.Minute
+)
+
+// oidcClient is nil unless an OpenID Connect provider is configured.
+var oidcClient *oidcConfig
+
+type oidcConfig struct {
+	issuer   string
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// initOIDC reads the provider's discovery document. Any standards compliant
+// provider works, including a local mock issuer during development:
+//
+//	OIDC_ISSUER_URL     issuer, e.g. https://accounts.google.com
+//	OIDC_CLIENT_ID      client registered with the provider
+//	OIDC_CLIENT_SECRET  left empty for public clients
+//	OIDC_REDIRECT_URL   defaults to FRONTEND_URL + /auth/oidc/callback
+//	OIDC_SCOPES         space separated, defaults to "openid email profile"
+func initOIDC() {
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		return
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		log.Fatalf("OIDC_CLIENT_ID is not set")
+	}
+
+	provider, err := oidc.NewProvider(context.Background(), issuer)
+	if err != nil {
+		log.Printf("Warning: OpenID Connect login disabled, could not load %s: %v", issuer, err)
+		return
+	}
+
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = frontendURL() + "/auth/oidc/callback"
+	}
+
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	oidcClient = &oidcConfig{
+		issuer: issuer,
+		oauth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}
+	log.Printf("OpenID Connect login enabled for %s", issuer)
+}
+
+type oidcStartRequest struct {
+	Club string `json:"club"` // Slug of the club a new account joins
+}
+
+type oidcCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// oidcStart returns the provider URL to send the user to. The frontend should
+// keep the state and only post a callback whose state matches it.
+func oidcStart(c *gin.Context) {
+	if oidcClient == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not enabled"})
+		return
+	}
+
+	var req oidcStartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, _, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	if err := db.CreateOIDCLogin(c.Request.Context(), stateHash, nonce, verifier, req.Club, time.Now().Add(oidcLoginTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	url := oidcClient.oauth2.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": url,
+		"state":             state,
+	})
+}
+
+// oidcCallback finishes a provider login. The provider account logs in as
+// the user it is linked to, otherwise it is linked to the user with the same
+// verified email, otherwise a new account is created.
+func oidcCallback(c *gin.Context) {
+	if oidcClient == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not enabled"})
+		return
+	}
+
+	var req oidcCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.State == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code and state are required"})
+		return
+	}
+
+	pending, err := db.ConsumeOIDCLogin(c.Request.Context(), hashToken(req.State))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login is invalid or expired, please try again"})
+		return
+	}
+
+	token, err := oidcClient.oauth2.Exchange(c.Request.Context(), req.Code, oauth2.VerifierOption(pending.CodeVerifier))
+	if err != nil {
+		log.Printf("OpenID Connect code exchange failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	idToken, err := oidcClient.verifier.Verify(c.Request.Context(), rawIDToken)
+	if err != nil {
+		log.Printf("OpenID Connect ID token rejected: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	// The nonce ties the ID token to the login we started
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(pending.Nonce)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	identity := db.Identity{Issuer: idToken.Issuer, Subject: idToken.Subject}
+
+	user, err := db.GetUserByIdentity(c.Request.Context(), identity.Issuer, identity.Subject)
+	if err != nil {
+		// Without a verified email anyone could claim someone else's account
+		if claims.Email == "" || !claims.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your provider did not confirm your email address"})
+			return
+		}
+
+		user, err = db.GetUserByEmail(c.Request.Context(), claims.Email)
+		if err != nil {
+			user, err = createOIDCUser(c, claims, pending.Club)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := db.LinkUserIdentity(c.Request.Context(), user.ID, identity); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Failed to link account"})
+			return
+		}
+		user.Verified = true
+	}
+
+	// The provider replaces the password, not the second factor
+	if user.TOTPEnabled {
+		requireSecondFactor(c, user)
+		return
+	}
+
+	respondWithTokens(c, http.StatusOK, user)
+}
+
+// createOIDCUser creates an account for a provider login as a viewer in the
+// club. It gets a random password, which can be replaced with a reset.
+func createOIDCUser(c *gin.Context, claims oidcClaims, slug string) (db.User, error) {
+	// Provider logins would otherwise get around the registration mode
+	if registrationMode != RegistrationOpen {
+		return db.User{}, fmt.Errorf("no account exists for this email and registration is %s", registrationModeDescription())
+	}
+	if slug == "" {
+		slug = db.DefaultClubSlug()
+	}
+	club, err := db.GetClubBySlug(c.Request.Context(), slug)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	password, _, err := newOpaqueToken()
+	if err != nil {
+		return db.User{}, err
+	}
+
+	return db.CreateUser(c.Request.Context(), claims.Email, password, name, db.RoleViewer, club.ID)
+}
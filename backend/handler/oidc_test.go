@@ -0,0 +1,477 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"fctracker/db"
+	"fctracker/keyring"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/oauth2"
+)
+
+const testClientID = "fctracker-test"
+
+// mockIssuer is a minimal OpenID Connect provider: discovery, a JWKS and a
+// token endpoint that checks PKCE. Tests stand in for the user at the
+// authorization endpoint by calling authorize.
+type mockIssuer struct {
+	*httptest.Server
+	keys *keyring.Keyring
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keyring.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := keyring.New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{keys: keys, grants: map[string]mockGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"keys": m.keys.JWKS()})
+	})
+	mux.HandleFunc("/token", m.token)
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// authorize plays the user logging in at the provider. It returns the code
+// the provider would redirect back with. claims are added to the ID token
+// and can override the defaults, including the nonce.
+func (m *mockIssuer) authorize(t *testing.T, authorizationURL, subject string, claims jwt.MapClaims) string {
+	t.Helper()
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID {
+		t.Fatalf("authorization URL without S256 PKCE or client: %s", authorizationURL)
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":   m.URL,
+		"sub":   subject,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		idClaims[k] = v
+	}
+
+	code := bson.NewObjectID().Hex()
+	m.mu.Lock()
+	m.grants[code] = mockGrant{challenge: q.Get("code_challenge"), claims: idClaims}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := m.keys.Sign(grant.claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// useMockIssuer points OpenID Connect login at the mock issuer for the test.
+func useMockIssuer(t *testing.T, m *mockIssuer) {
+	t.Helper()
+	t.Setenv("OIDC_ISSUER_URL", m.URL)
+	t.Setenv("OIDC_CLIENT_ID", testClientID)
+	t.Setenv("OIDC_REDIRECT_URL", "http://localhost:5173/auth/oidc/callback")
+
+	previous := oidcClient
+	t.Cleanup(func() { oidcClient = previous })
+	initOIDC()
+	if oidcClient == nil {
+		t.Fatal("OpenID Connect wasn't enabled for the mock issuer")
+	}
+}
+
+func oidcRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/api/auth/oidc/start", oidcStart)
+	r.POST("/api/auth/oidc/callback", oidcCallback)
+	return r
+}
+
+func TestInitOIDCDiscovery(t *testing.T) {
+	m := newMockIssuer(t)
+	useMockIssuer(t, m)
+
+	if oidcClient.issuer != m.URL {
+		t.Errorf("issuer = %s, want %s", oidcClient.issuer, m.URL)
+	}
+	if oidcClient.oauth2.Endpoint.TokenURL != m.URL+"/token" || oidcClient.oauth2.Endpoint.AuthURL != m.URL+"/authorize" {
+		t.Errorf("endpoints = %+v", oidcClient.oauth2.Endpoint)
+	}
+	if got := oidcClient.oauth2.Scopes; len(got) != 3 {
+		t.Errorf("scopes = %v, want openid email profile", got)
+	}
+}
+
+func TestInitOIDCUnreachableIssuer(t *testing.T) {
+	m := newMockIssuer(t)
+	m.Close()
+
+	t.Setenv("OIDC_ISSUER_URL", m.URL)
+	t.Setenv("OIDC_CLIENT_ID", testClientID)
+	previous := oidcClient
+	t.Cleanup(func() { oidcClient = previous })
+	oidcClient = nil
+
+	initOIDC()
+	if oidcClient != nil {
+		t.Fatal("login enabled without a discovery document")
+	}
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	previous := oidcClient
+	t.Cleanup(func() { oidcClient = previous })
+	oidcClient = nil
+
+	r := oidcRouter()
+	for _, path := range []string{"/api/auth/oidc/start", "/api/auth/oidc/callback"} {
+		if w, _ := postJSON(t, r, path, gin.H{}); w.Code != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestOIDCCallbackNeedsCodeAndState(t *testing.T) {
+	useMockIssuer(t, newMockIssuer(t))
+	r := oidcRouter()
+
+	for _, body := range []gin.H{{}, {"code": "abc"}, {"state": "abc"}} {
+		if w, _ := postJSON(t, r, "/api/auth/oidc/callback", body); w.Code != http.StatusBadRequest {
+			t.Errorf("callback with %v = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+// The exchange and ID token checks the callback relies on, without the
+// database steps around them
+func TestOIDCExchangeAndVerify(t *testing.T) {
+	m := newMockIssuer(t)
+	useMockIssuer(t, m)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		verifier   string
+		wantVerify bool
+	}{
+		{"valid", jwt.MapClaims{"email": "a@example.com"}, "", true},
+		{"wrong PKCE verifier", nil, "not-the-verifier", false},
+		{"other audience", jwt.MapClaims{"aud": "other-client"}, "", false},
+		{"other issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, "", false},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := oauth2.GenerateVerifier()
+			authURL := oidcClient.oauth2.AuthCodeURL("state", oidc.Nonce("nonce"), oauth2.S256ChallengeOption(verifier))
+			code := m.authorize(t, authURL, "subject", tt.claims)
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			token, err := oidcClient.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+			if err != nil {
+				if tt.wantVerify {
+					t.Fatalf("exchange failed: %v", err)
+				}
+				return
+			}
+
+			rawIDToken, _ := token.Extra("id_token").(string)
+			idToken, err := oidcClient.verifier.Verify(ctx, rawIDToken)
+			if (err == nil) != tt.wantVerify {
+				t.Fatalf("Verify error = %v, want success %v", err, tt.wantVerify)
+			}
+			if err == nil && (idToken.Nonce != "nonce" || idToken.Subject != "subject") {
+				t.Errorf("ID token nonce %q subject %q", idToken.Nonce, idToken.Subject)
+			}
+		})
+	}
+}
+
+// startOIDCLogin begins a login and returns the state and the URL the user
+// is sent to.
+func startOIDCLogin(t *testing.T, r http.Handler) (string, string) {
+	t.Helper()
+	w, resp := postJSON(t, r, "/api/auth/oidc/start", gin.H{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("start = %d %v", w.Code, resp)
+	}
+	return resp["state"].(string), resp["authorization_url"].(string)
+}
+
+// oidcTest sets up a database backed login against a new mock issuer.
+func oidcTest(t *testing.T) (*mockIssuer, *gin.Engine) {
+	t.Helper()
+	requireMongo(t)
+	useTestKeys(t)
+
+	previous := cookiesEnabled
+	cookiesEnabled = false
+	t.Cleanup(func() { cookiesEnabled = previous })
+
+	m := newMockIssuer(t)
+	useMockIssuer(t, m)
+	return m, oidcRouter()
+}
+
+// cleanupEmail deletes the account created for email when the test ends.
+func cleanupEmail(t *testing.T, email string) {
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if user, err := db.GetUserByEmail(ctx, email); err == nil {
+			db.DeleteUser(db.WithTenant(ctx, user.TenantID), user.ID)
+		}
+	})
+}
+
+func TestOIDCLoginCreatesAndLinksAccount(t *testing.T) {
+	m, r := oidcTest(t)
+	previousMode := registrationMode
+	registrationMode = RegistrationOpen
+	t.Cleanup(func() { registrationMode = previousMode })
+
+	email := "sso-" + bson.NewObjectID().Hex() + "@example.com"
+	cleanupEmail(t, email)
+	subject := bson.NewObjectID().Hex()
+	claims := jwt.MapClaims{"email": email, "email_verified": true, "name": "Sam Keeper"}
+
+	state, authURL := startOIDCLogin(t, r)
+	code := m.authorize(t, authURL, subject, claims)
+	w, resp := postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state})
+	if w.Code != http.StatusOK {
+		t.Fatalf("first login = %d %v", w.Code, resp)
+	}
+	if resp["token"] == nil || resp["refresh_token"] == nil {
+		t.Fatalf("first login didn't issue tokens: %v", resp)
+	}
+
+	user, err := db.GetUserByIdentity(context.Background(), m.URL, subject)
+	if err != nil {
+		t.Fatalf("provider account wasn't linked: %v", err)
+	}
+	if user.Email != email || user.Name != "Sam Keeper" || user.Role != db.RoleViewer {
+		t.Errorf("created user = %+v", user)
+	}
+
+	// Logging in again finds the linked account, whatever the email now is
+	state, authURL = startOIDCLogin(t, r)
+	code = m.authorize(t, authURL, subject, jwt.MapClaims{"email": "changed@example.com", "email_verified": true})
+	w, resp = postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state})
+	if w.Code != http.StatusOK {
+		t.Fatalf("second login = %d %v", w.Code, resp)
+	}
+	loggedIn, _ := resp["user"].(map[string]any)
+	if loggedIn["email"] != email {
+		t.Errorf("second login was user %v, want %s", loggedIn["email"], email)
+	}
+}
+
+func TestOIDCLinksExistingAccountByVerifiedEmail(t *testing.T) {
+	m, r := oidcTest(t)
+	user := createTestUser(t, db.RoleCoach)
+	subject := bson.NewObjectID().Hex()
+
+	state, authURL := startOIDCLogin(t, r)
+	code := m.authorize(t, authURL, subject, jwt.MapClaims{"email": user.Email, "email_verified": true})
+	if w, resp := postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state}); w.Code != http.StatusOK {
+		t.Fatalf("login = %d %v", w.Code, resp)
+	}
+
+	linked, err := db.GetUserByIdentity(context.Background(), m.URL, subject)
+	if err != nil || linked.ID != user.ID {
+		t.Fatalf("provider account linked to %v, %v, want %s", linked.ID, err, user.ID.Hex())
+	}
+}
+
+func TestOIDCRejectedLogins(t *testing.T) {
+	m, r := oidcTest(t)
+	user := createTestUser(t, db.RoleCoach)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		// Breaks the exchange after the provider step
+		tamper func(code, state string) (string, string)
+		want   int
+	}{
+		{
+			name:   "unverified email can't take over an account",
+			claims: jwt.MapClaims{"email": user.Email, "email_verified": false},
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "missing email",
+			claims: jwt.MapClaims{"email_verified": true},
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "ID token for another login",
+			claims: jwt.MapClaims{"email": user.Email, "email_verified": true, "nonce": "someone-elses-nonce"},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "ID token for another client",
+			claims: jwt.MapClaims{"email": user.Email, "email_verified": true, "aud": "other-client"},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "expired ID token",
+			claims: jwt.MapClaims{"email": user.Email, "email_verified": true, "exp": time.Now().Add(-time.Hour).Unix()},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "code the provider didn't issue",
+			claims: jwt.MapClaims{"email": user.Email, "email_verified": true},
+			tamper: func(code, state string) (string, string) { return "forged-code", state },
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown state",
+			claims: jwt.MapClaims{"email": user.Email, "email_verified": true},
+			tamper: func(code, state string) (string, string) { return code, "forged-state" },
+			want:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, authURL := startOIDCLogin(t, r)
+			code := m.authorize(t, authURL, bson.NewObjectID().Hex(), tt.claims)
+			if tt.tamper != nil {
+				code, state = tt.tamper(code, state)
+			}
+
+			w, resp := postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state})
+			if w.Code != tt.want {
+				t.Errorf("callback = %d %v, want %d", w.Code, resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	m, r := oidcTest(t)
+	user := createTestUser(t, db.RoleCoach)
+	claims := jwt.MapClaims{"email": user.Email, "email_verified": true}
+
+	state, authURL := startOIDCLogin(t, r)
+	code := m.authorize(t, authURL, bson.NewObjectID().Hex(), claims)
+	if w, resp := postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state}); w.Code != http.StatusOK {
+		t.Fatalf("login = %d %v", w.Code, resp)
+	}
+
+	code = m.authorize(t, authURL, bson.NewObjectID().Hex(), claims)
+	if w, _ := postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state}); w.Code != http.StatusBadRequest {
+		t.Fatalf("replayed state = %d, want 400", w.Code)
+	}
+}
+
+func TestOIDCFollowsRegistrationMode(t *testing.T) {
+	m, r := oidcTest(t)
+	previousMode := registrationMode
+	registrationMode = RegistrationInvite
+	t.Cleanup(func() { registrationMode = previousMode })
+
+	email := "sso-" + bson.NewObjectID().Hex() + "@example.com"
+	cleanupEmail(t, email)
+
+	state, authURL := startOIDCLogin(t, r)
+	code := m.authorize(t, authURL, bson.NewObjectID().Hex(), jwt.MapClaims{"email": email, "email_verified": true})
+	if w, _ := postJSON(t, r, "/api/auth/oidc/callback", gin.H{"code": code, "state": state}); w.Code != http.StatusBadRequest {
+		t.Fatalf("new account in invite mode = %d, want 400", w.Code)
+	}
+	if _, err := db.GetUserByEmail(context.Background(), email); err == nil {
+		t.Fatal("account was created although registration is invite only")
+	}
+}
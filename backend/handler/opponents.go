@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func addOpponent(c *gin.Context) {
+	opponent, err := db.AddOpponent(c.Request.Context(), c.Query("name"))
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "error adding opponent", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "opponent added", "opponent": opponent, "error": ""})
+}
+
+func getOpponents(c *gin.Context) {
+	opponents, err := db.GetOpponents(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"opponents": opponents, "error": ""})
+}
+
+func getTeamFixtures(c *gin.Context) {
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := db.GetTeamById(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	fixtures, err := db.GetTeamFixtures(c.Request.Context(), id, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fixtures": fixtures})
+}
+
+// fixtureSide looks up one side of a fixture, which is either one of the
+// club's teams or an opponent.
+func fixtureSide(c *gin.Context, id string) (bson.ObjectID, bool, error) {
+	if team, err := db.GetTeamById(c.Request.Context(), id); err == nil {
+		return team.ID, true, nil
+	}
+	if opponent, err := db.GetOpponentByID(c.Request.Context(), id); err == nil {
+		return opponent.ID, false, nil
+	}
+	return bson.ObjectID{}, false, fmt.Errorf("%s is not a team or opponent", id)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	passwordResetTTL = time.Hour
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func forgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required"})
+		return
+	}
+
+	// Same answer whether or not the account exists
+	response := gin.H{"message": "If an account exists for this email, a reset link has been sent"}
+
+	user, err := db.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	err = sendPasswordResetEmail(c, user,
+		"Someone asked to reset the password for your FC Tracker account.",
+		"If this wasn't you, you can ignore this email.",
+	)
+	if err != nil {
+		log.Printf("Failed to store reset token for %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// sendPasswordResetEmail emails the user a link to choose a new password.
+// reason opens the email and says why it was sent, closing ends it.
+func sendPasswordResetEmail(c *gin.Context, user db.User, reason, closing string) error {
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.CreateUserToken(c.Request.Context(), user.ID, db.TokenPurposePasswordReset, tokenHash, time.Now().Add(passwordResetTTL))
+	if err != nil {
+		return err
+	}
+
+	link := frontendURL() + "/reset-password?token=" + token
+	sendMail(user.Email, "Reset your FC Tracker password", fmt.Sprintf(
+		"Hi %s,\n\n%s "+
+			"Use the link below within the next hour to choose a new one:\n\n%s\n\n%s",
+		user.Name, reason, link, closing,
+	))
+	return nil
+}
+
+func resetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token and password are required"})
+		return
+	}
+
+	// Checked before the token is used up, so a rejected password can be retried
+	if !checkPasswordPolicy(c, req.Password) {
+		return
+	}
+
+	token, err := db.ConsumeUserToken(c.Request.Context(), db.TokenPurposePasswordReset, hashToken(req.Token))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or has expired"})
+		return
+	}
+
+	if err := db.SetUserPassword(c.Request.Context(), token.UserID, req.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Anyone holding the old password may also hold a session
+	if err := db.RevokeUserRefreshTokens(c.Request.Context(), token.UserID); err != nil {
+		log.Printf("Failed to revoke sessions for %s after reset: %v", token.UserID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please log in."})
+}
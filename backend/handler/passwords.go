@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"fctracker/db"
+	"fctracker/passwords"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPasswordMinLength = 8
+	passwordMaxLength        = 128
+)
+
+var passwordPolicy *passwords.Policy
+
+// initPasswords reads the argon2id cost and the password policy from the
+// environment. PASSWORD_BLOCKLIST_FILE adds a list of breached passwords,
+// one per line, to the built-in list of common ones.
+func initPasswords() {
+	params := passwords.DefaultParams
+	params.Memory = uint32(intFromEnv("PASSWORD_ARGON2_MEMORY_KIB", int(params.Memory)))
+	params.Iterations = uint32(intFromEnv("PASSWORD_ARGON2_ITERATIONS", int(params.Iterations)))
+	params.Parallelism = uint8(intFromEnv("PASSWORD_ARGON2_PARALLELISM", int(params.Parallelism)))
+
+	hasher, err := passwords.NewHasher(params)
+	if err != nil {
+		log.Fatalf("Invalid password hashing parameters: %v", err)
+	}
+	db.SetPasswordHasher(hasher)
+
+	passwordPolicy = passwords.NewPolicy(intFromEnv("PASSWORD_MIN_LENGTH", defaultPasswordMinLength), passwordMaxLength)
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		n, err := passwordPolicy.LoadBlocklist(path)
+		if err != nil {
+			log.Fatalf("Failed to load PASSWORD_BLOCKLIST_FILE: %v", err)
+		}
+		log.Printf("Loaded %d blocked passwords from %s", n, path)
+	}
+}
+
+// intFromEnv reads a positive integer from the environment, falling back to
+// def.
+func intFromEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Warning: ignoring %s: not a positive integer", name)
+		return def
+	}
+	return n
+}
+
+// checkPasswordPolicy writes a 400 and returns false when a new password
+// isn't acceptable. personal holds the user's name and email.
+func checkPasswordPolicy(c *gin.Context, password string, personal ...string) bool {
+	if err := passwordPolicy.Check(password, personal...); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// verifyPassword checks the user's password and, when it matches a hash
+// made with bcrypt or older argon2id settings, stores a fresh hash.
+func verifyPassword(c *gin.Context, user db.User, password string) bool {
+	ok, rehash := db.CheckPassword(user.Password, password)
+	if ok && rehash {
+		if err := db.SetUserPassword(c.Request.Context(), user.ID, password); err != nil {
+			log.Printf("Failed to upgrade password hash for %s: %v", user.ID.Hex(), err)
+		}
+	}
+	return ok
+}
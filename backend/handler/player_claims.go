@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Fields a linked player may change on their own record. Stats are only
+// changed by coaches and fixtures.
+type updateMyPlayerRequest struct {
+	Name     *string `json:"name"`
+	DOB      *string `json:"dob"`
+	Position *string `json:"position"`
+	FunFact  *string `json:"fun_fact"`
+}
+
+func claimPlayer(c *gin.Context) {
+	player, err := db.GetPlayerByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
+		return
+	}
+
+	if !player.UserID.IsZero() {
+		c.JSON(http.StatusConflict, gin.H{"error": "This player is already linked to an account"})
+		return
+	}
+	if _, err := db.GetPlayerByUserID(c.Request.Context(), c.GetString("userID")); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Your account is already linked to a player"})
+		return
+	}
+
+	userID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	claim, err := db.CreatePlayerClaim(c.Request.Context(), userID, player)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"claim": claim, "message": "Claim sent. A coach of the team has to approve it."})
+}
+
+func getTeamPlayerClaims(c *gin.Context) {
+	teamID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team id"})
+		return
+	}
+
+	if !requireTeamManager(c, teamID) {
+		return
+	}
+
+	claims, err := db.GetTeamPlayerClaims(c.Request.Context(), teamID.Hex(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load claims"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"claims": claims})
+}
+
+// pendingClaimForManager loads a pending claim the current user may decide
+// on, writing an error when there is none.
+func pendingClaimForManager(c *gin.Context) (db.PlayerClaim, bool) {
+	claim, err := db.GetPlayerClaim(c.Request.Context(), c.Param("id"))
+	if err != nil || claim.Status != db.ClaimPending {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return db.PlayerClaim{}, false
+	}
+	if !requireTeamManager(c, claim.TeamID) {
+		return db.PlayerClaim{}, false
+	}
+	return claim, true
+}
+
+func approvePlayerClaim(c *gin.Context) {
+	claim, ok := pendingClaimForManager(c)
+	if !ok {
+		return
+	}
+
+	if err := db.LinkPlayerToUser(c.Request.Context(), claim.PlayerID, claim.UserID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	managerID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	claim, err := db.DecidePlayerClaim(c.Request.Context(), claim.ID, db.ClaimApproved, managerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve claim"})
+		return
+	}
+
+	// Being linked makes the user a player of the team, with the global role
+	// to match. Never demote anyone here.
+	if _, err := db.GetMembership(c.Request.Context(), claim.UserID.Hex(), claim.TeamID.Hex()); err != nil {
+		_, err := db.AddMembership(c.Request.Context(), claim.UserID, claim.TeamID, db.TeamRolePlayer, db.MembershipActive, managerID)
+		if err != nil {
+			log.Printf("Failed to add %s to team %s after claim: %v", claim.UserID.Hex(), claim.TeamID.Hex(), err)
+		}
+	}
+	user, err := db.GetClubUserByID(c.Request.Context(), claim.UserID.Hex())
+	if err == nil && roleRank[db.RolePlayer] > roleRank[user.GetRole()] {
+		if err := db.SetUserRole(c.Request.Context(), user.ID.Hex(), db.RolePlayer); err != nil {
+			log.Printf("Failed to make %s a player after claim: %v", user.ID.Hex(), err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"claim": claim, "message": "Claim approved"})
+}
+
+func rejectPlayerClaim(c *gin.Context) {
+	claim, ok := pendingClaimForManager(c)
+	if !ok {
+		return
+	}
+
+	managerID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	claim, err := db.DecidePlayerClaim(c.Request.Context(), claim.ID, db.ClaimRejected, managerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject claim"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"claim": claim, "message": "Claim rejected"})
+}
+
+// myPlayer loads the player linked to the current user, writing a 404 when
+// there is none.
+func myPlayer(c *gin.Context) (db.Player, bool) {
+	player, err := db.GetPlayerByUserID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Your account is not linked to a player"})
+		return db.Player{}, false
+	}
+	return player, true
+}
+
+func getMyPlayer(c *gin.Context) {
+	player, ok := myPlayer(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"player": player})
+}
+
+func getMyFixtures(c *gin.Context) {
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	player, ok := myPlayer(c)
+	if !ok {
+		return
+	}
+
+	fixtures, err := db.GetPlayerFixtures(c.Request.Context(), player.ID.Hex(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fixtures": fixtures})
+}
+
+func updateMyPlayer(c *gin.Context) {
+	var req updateMyPlayerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	player, ok := myPlayer(c)
+	if !ok {
+		return
+	}
+
+	update := make(map[string]any)
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+			return
+		}
+		update["name"] = name
+	}
+	if req.DOB != nil {
+		dob, err := parseDOB(*req.DOB)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		update["dob"] = dob
+	}
+	if req.Position != nil {
+		update["position"] = *req.Position
+	}
+	if req.FunFact != nil {
+		update["fun_fact"] = *req.FunFact
+	}
+
+	if len(update) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+
+	if _, err := db.UpdatePlayerByID(c.Request.Context(), player.ID.Hex(), update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update player"})
+		return
+	}
+
+	player, ok = myPlayer(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"player": player})
+}
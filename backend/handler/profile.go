@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateProfileRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// currentUser loads the authenticated user, writing a 404 when the account
+// no longer exists.
+func currentUser(c *gin.Context) (db.User, bool) {
+	user, err := db.GetClubUserByID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return db.User{}, false
+	}
+	return user, true
+}
+
+// checkCurrentPassword guards account changes the same way as login, so a
+// stolen session can't be used to guess the password.
+func checkCurrentPassword(c *gin.Context, user db.User, password string) bool {
+	if !checkLoginAllowed(c, user.Email) {
+		return false
+	}
+	if !verifyPassword(c, user, password) {
+		recordLoginFailure(c, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return false
+	}
+	return true
+}
+
+func updateMe(c *gin.Context) {
+	var req updateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	name, email := user.Name, user.Email
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+	}
+	if req.Email != nil {
+		email = strings.TrimSpace(*req.Email)
+	}
+	if name == "" || email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and email cannot be empty"})
+		return
+	}
+
+	if email != user.Email {
+		if _, err := db.GetUserByEmail(c.Request.Context(), email); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "This email is already in use"})
+			return
+		}
+	}
+
+	updated, err := db.UpdateUserProfile(c.Request.Context(), user.ID, name, email)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	// A new address has to be confirmed before it counts as verified
+	if updated.Email != user.Email {
+		if err := sendVerificationEmail(c, updated); err != nil {
+			log.Printf("Failed to send verification email to %s: %v", updated.ID.Hex(), err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": userResponse(updated),
+	})
+}
+
+func changePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current and new password are required"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !checkPasswordPolicy(c, req.NewPassword, user.Name, user.Email) {
+		return
+	}
+	if !checkCurrentPassword(c, user, req.CurrentPassword) {
+		return
+	}
+	recordLoginSuccess(c, user.Email)
+
+	if err := db.SetUserPassword(c.Request.Context(), user.ID, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	// Sign out every other session and start a fresh one for this device
+	if err := db.RevokeUserRefreshTokens(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions for %s after password change: %v", user.ID.Hex(), err)
+	}
+
+	respondWithTokens(c, http.StatusOK, user)
+}
+
+func deleteMe(c *gin.Context) {
+	var req deleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is required"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !checkCurrentPassword(c, user, req.Password) {
+		return
+	}
+
+	// Don't leave a club without anyone who can manage it
+	if user.GetRole() == db.RoleAdmin {
+		admins, err := db.CountClubAdmins(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+			return
+		}
+		if admins <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Make another member an admin before deleting the last admin account"})
+			return
+		}
+	}
+
+	if err := db.DeleteUser(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	recordLoginSuccess(c, user.Email)
+
+	if cookiesEnabled {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"fctracker/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+var rateLimitStore ratelimit.Store
+
+func initRateLimit() {
+	rateLimitStore = ratelimit.NewMemoryStore()
+}
+
+// rateLimitFromEnv reads a limit such as "10/1m" from the environment,
+// falling back to def.
+func rateLimitFromEnv(name string, def ratelimit.Limit) ratelimit.Limit {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		log.Printf("Warning: ignoring %s: %v", name, err)
+		return def
+	}
+	return limit
+}
+
+// RateLimit limits requests per authenticated user, or per client IP before
+// login. Each group name gets its own buckets.
+func RateLimit(group string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := group + ":ip:" + c.ClientIP()
+		if userID := c.GetString("userID"); userID != "" {
+			key = group + ":user:" + userID
+		}
+
+		allowed, wait, err := rateLimitStore.Take(c.Request.Context(), key, limit, time.Now())
+		if err != nil {
+			// Don't take the API down with the limiter
+			log.Printf("Rate limiter error: %v", err)
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests. Please slow down."})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
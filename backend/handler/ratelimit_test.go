@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fctracker/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	previous := rateLimitStore
+	rateLimitStore = ratelimit.NewMemoryStore()
+	t.Cleanup(func() { rateLimitStore = previous })
+
+	r := gin.New()
+	r.GET("/limited", RateLimit("test", ratelimit.Every(1, time.Minute)), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	get := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("192.0.2.1"); w.Code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", w.Code)
+	}
+	w := get("192.0.2.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+
+	// Buckets are per client IP
+	if w := get("192.0.2.2"); w.Code != http.StatusOK {
+		t.Fatalf("another client = %d, want 200", w.Code)
+	}
+}
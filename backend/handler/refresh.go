@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// newOpaqueToken returns a random URL-safe token and the hash we persist.
+func newOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens creates an access token and a refresh token belonging to the
+// given token family, and records the device in the family's session.
+func issueTokens(c *gin.Context, user db.User, familyID bson.ObjectID) (string, string, error) {
+	refreshToken, refreshHash, err := newOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	expiresAt := time.Now().Add(refreshTokenTTL)
+	_, err = db.CreateRefreshToken(c.Request.Context(), user.ID, familyID, refreshHash, expiresAt)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = db.SaveSession(c.Request.Context(), familyID, user.ID, c.Request.UserAgent(), c.ClientIP(), expiresAt)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := generateToken(user, familyID.Hex())
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// refreshTokenFromRequest reads the refresh token from the body, or from the
+// cookie in cookie mode. It writes an error and returns false when missing.
+func refreshTokenFromRequest(c *gin.Context) (string, bool) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err == nil && req.RefreshToken != "" {
+		return req.RefreshToken, true
+	}
+
+	if cookie, err := c.Cookie(refreshCookie); cookiesEnabled && err == nil && cookie != "" {
+		if !validCSRF(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			return "", false
+		}
+		return cookie, true
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+	return "", false
+}
+
+func refresh(c *gin.Context) {
+	refreshToken, ok := refreshTokenFromRequest(c)
+	if !ok {
+		return
+	}
+
+	stored, err := db.GetRefreshTokenByHash(c.Request.Context(), hashToken(refreshToken))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	if stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	// A used token coming back means it was stolen or replayed, so the whole
+	// family is killed and the user has to log in again.
+	if err := db.MarkRefreshTokenUsed(c.Request.Context(), stored.ID); err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			log.Printf("Refresh token reuse detected for user %s, revoking family %s", stored.UserID.Hex(), stored.FamilyID.Hex())
+			if err := db.RevokeRefreshTokenFamily(c.Request.Context(), stored.FamilyID); err != nil {
+				log.Printf("Failed to revoke refresh token family %s: %v", stored.FamilyID.Hex(), err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), stored.UserID.Hex())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been disabled"})
+		return
+	}
+
+	token, newRefreshToken, err := issueTokens(c, user, stored.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	body := gin.H{
+		"expires_in": int(accessTokenTTL.Seconds()),
+	}
+	if !writeTokens(c, body, token, newRefreshToken) {
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func logout(c *gin.Context) {
+	refreshToken, ok := refreshTokenFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// Unknown tokens are treated as already logged out
+	stored, err := db.GetRefreshTokenByHash(c.Request.Context(), hashToken(refreshToken))
+	if err == nil {
+		if err := db.RevokeRefreshTokenFamily(c.Request.Context(), stored.FamilyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	if cookiesEnabled {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
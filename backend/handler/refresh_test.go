@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNewOpaqueToken(t *testing.T) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 43 {
+		t.Errorf("token length = %d, want 43 characters for 32 random bytes", len(token))
+	}
+	if hash != hashToken(token) || hash == token {
+		t.Error("stored hash isn't the hash of the token")
+	}
+
+	other, _, err := newOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == other {
+		t.Error("two tokens are equal")
+	}
+}
+
+func TestRefreshNeedsToken(t *testing.T) {
+	defer func(enabled bool) { cookiesEnabled = enabled }(cookiesEnabled)
+	cookiesEnabled = false
+
+	r := gin.New()
+	r.POST("/api/auth/refresh", refresh)
+	r.POST("/api/auth/logout", logout)
+
+	for _, path := range []string{"/api/auth/refresh", "/api/auth/logout"} {
+		w, _ := postJSON(t, r, path, gin.H{})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s without a token = %d, want 400", path, w.Code)
+		}
+	}
+}
+
+// refreshRouter serves the token routes in bearer mode and returns a fresh
+// refresh token for a new user.
+func refreshRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	requireMongo(t)
+	useTestKeys(t)
+
+	previous := cookiesEnabled
+	cookiesEnabled = false
+	t.Cleanup(func() { cookiesEnabled = previous })
+
+	user := createTestUser(t, db.RoleCoach)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	_, refreshToken, err := issueTokens(c, user, bson.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/api/auth/refresh", refresh)
+	r.POST("/api/auth/logout", logout)
+	return r, refreshToken
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	r, first := refreshRouter(t)
+
+	w, resp := postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": first})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh = %d %v", w.Code, resp)
+	}
+	second, _ := resp["refresh_token"].(string)
+	access, _ := resp["token"].(string)
+	if second == "" || second == first || access == "" {
+		t.Fatalf("refresh didn't rotate the tokens: %v", resp)
+	}
+	if _, err := validateToken(access); err != nil {
+		t.Fatalf("new access token doesn't validate: %v", err)
+	}
+
+	// The rotated token keeps working until it is used
+	w, resp = postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": second})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh with the rotated token = %d %v", w.Code, resp)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	r, first := refreshRouter(t)
+
+	w, resp := postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": first})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh = %d %v", w.Code, resp)
+	}
+	second := resp["refresh_token"].(string)
+
+	// Replaying the used token looks like theft
+	if w, _ := postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": first}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed token = %d, want 401", w.Code)
+	}
+
+	// so the token the legitimate holder got is dead as well
+	if w, _ := postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": second}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("token from a revoked family = %d, want 401", w.Code)
+	}
+}
+
+func TestRefreshAfterLogout(t *testing.T) {
+	r, token := refreshRouter(t)
+
+	if w, resp := postJSON(t, r, "/api/auth/logout", gin.H{"refresh_token": token}); w.Code != http.StatusOK {
+		t.Fatalf("logout = %d %v", w.Code, resp)
+	}
+	if w, _ := postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": token}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout = %d, want 401", w.Code)
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpiredTokens(t *testing.T) {
+	r, _ := refreshRouter(t)
+	user := createTestUser(t, db.RoleViewer)
+
+	expired, expiredHash, err := newOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.CreateRefreshToken(context.Background(), user.ID, bson.NewObjectID(), expiredHash, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"unknown": "not-a-real-token",
+		"expired": expired,
+	} {
+		if w, _ := postJSON(t, r, "/api/auth/refresh", gin.H{"refresh_token": token}); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s token = %d, want 401", name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fixtureFilter reads the ?season=&competition= filters shared by the
+// fixture, stat and leaderboard endpoints. season=current picks the season
+// today falls in. It writes a 400 and returns false when a filter is invalid.
+func fixtureFilter(c *gin.Context) (db.FixtureFilter, bool) {
+	var filter db.FixtureFilter
+
+	switch season := c.Query("season"); season {
+	case "":
+	case "current":
+		current, err := db.GetSeasonOn(c.Request.Context(), time.Now())
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No season is in progress"})
+			return filter, false
+		}
+		filter.SeasonID = current.ID
+	default:
+		found, err := db.GetSeasonByID(c.Request.Context(), season)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Season not found"})
+			return filter, false
+		}
+		filter.SeasonID = found.ID
+	}
+
+	if competition := c.Query("competition"); competition != "" {
+		found, err := db.GetCompetitionByID(c.Request.Context(), competition)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Competition not found"})
+			return filter, false
+		}
+		filter.CompetitionID = found.ID
+	}
+
+	return filter, true
+}
+
+func addSeason(c *gin.Context) {
+	start, err := parseDate(c.Query("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start: " + err.Error()})
+		return
+	}
+	end, err := parseDate(c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end: " + err.Error()})
+		return
+	}
+	// A season ending on a day includes the matches played that day
+	if end.Equal(end.Truncate(24 * time.Hour)) {
+		end = end.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+
+	season, err := db.AddSeason(c.Request.Context(), c.Query("name"), start, end)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error adding season", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "season added", "season": season, "error": ""})
+}
+
+func getSeasons(c *gin.Context) {
+	seasons, err := db.GetSeasons(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"seasons": seasons, "error": ""})
+}
+
+func addCompetition(c *gin.Context) {
+	competition, err := db.AddCompetition(c.Request.Context(), c.Query("name"), c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error adding competition", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "competition added", "competition": competition, "error": ""})
+}
+
+func getCompetitions(c *gin.Context) {
+	competitions, err := db.GetCompetitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"competitions": competitions, "error": ""})
+}
+
+// seasonAndCompetition resolves the optional seasonId and competitionId
+// query parameters used when adding or assigning a fixture.
+func seasonAndCompetition(c *gin.Context) (bson.ObjectID, bson.ObjectID, bool) {
+	var seasonID, competitionID bson.ObjectID
+
+	if id := c.Query("seasonId"); id != "" {
+		season, err := db.GetSeasonByID(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Season not found"})
+			return seasonID, competitionID, false
+		}
+		seasonID = season.ID
+	}
+	if id := c.Query("competitionId"); id != "" {
+		competition, err := db.GetCompetitionByID(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Competition not found"})
+			return seasonID, competitionID, false
+		}
+		competitionID = competition.ID
+	}
+
+	return seasonID, competitionID, true
+}
+
+func assignFixture(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := bson.ObjectIDFromHex(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fixture id"})
+		return
+	}
+	if !requireFixtureManagerByID(c, id) {
+		return
+	}
+
+	seasonID, competitionID, ok := seasonAndCompetition(c)
+	if !ok {
+		return
+	}
+	if seasonID.IsZero() && competitionID.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seasonId or competitionId is required"})
+		return
+	}
+
+	if err := db.AssignFixture(c.Request.Context(), id, seasonID, competitionID); err != nil {
+		if errors.Is(err, db.ErrFixtureNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fixture, err := db.GetFixtureByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fixture": fixture})
+}
+
+func getPlayerStats(c *gin.Context) {
+	filter, ok := fixtureFilter(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := db.GetPlayerByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
+		return
+	}
+
+	stats, err := db.GetPlayerStats(c.Request.Context(), id, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestAssignFixtureInvalidID(t *testing.T) {
+	r := gin.New()
+	r.POST("/api/fixture/:id/assign", assignFixture)
+
+	if w, resp := postJSON(t, r, "/api/fixture/not-an-id/assign?seasonId=x", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("assign with an invalid id = %d %v, want 400", w.Code, resp)
+	}
+}
+
+func TestFixtureFilterEverything(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/fixtures", nil)
+
+	filter, ok := fixtureFilter(c)
+	if !ok || filter != (db.FixtureFilter{}) {
+		t.Fatalf("filter without a season or competition = %+v, %v, want everything", filter, ok)
+	}
+}
+
+// adminRouter serves the route to a new admin and returns a function making
+// their requests to it.
+func adminRouter(t *testing.T, method, route string, handler gin.HandlerFunc) (db.User, func(path string) *httptest.ResponseRecorder) {
+	t.Helper()
+	requireMongo(t)
+	useTestKeys(t)
+
+	user := createTestUser(t, db.RoleAdmin)
+	token, err := generateToken(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Handle(method, route, AuthMiddleware(), handler)
+	return user, func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestFixtureFilterCurrentSeason(t *testing.T) {
+	user, get := adminRouter(t, http.MethodGet, "/filter", func(c *gin.Context) {
+		if filter, ok := fixtureFilter(c); ok {
+			c.JSON(http.StatusOK, gin.H{"season": filter.SeasonID.Hex()})
+		}
+	})
+
+	if w := get("/filter?season=current"); w.Code != http.StatusNotFound {
+		t.Fatalf("current season without one = %d, want 404", w.Code)
+	}
+
+	ctx := db.WithTenant(context.Background(), user.TenantID)
+	now := time.Now()
+	season, err := db.AddSeason(ctx, "This season", now.AddDate(0, -1, 0), now.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := get("/filter?season=current")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), season.ID.Hex()) {
+		t.Fatalf("current season = %d %s, want %s", w.Code, w.Body, season.ID.Hex())
+	}
+	if w := get("/filter?season=" + bson.NewObjectID().Hex()); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown season = %d, want 400", w.Code)
+	}
+}
+
+func TestAssignMissingFixture(t *testing.T) {
+	_, post := adminRouter(t, http.MethodPost, "/api/fixture/:id/assign", assignFixture)
+
+	if w := post("/api/fixture/" + bson.NewObjectID().Hex() + "/assign"); w.Code != http.StatusNotFound {
+		t.Fatalf("assigning a missing fixture = %d %s, want 404", w.Code, w.Body)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"fctracker/db"
+	"fctracker/ratelimit"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -21,19 +24,25 @@ var (
 	router = gin.Default()
 
 	port = "9090"
-	
+
 	s *http.Server
-	)
+)
 
 func Start() {
-	initJWTSecret()
+	initJWTKeys()
+	initPasswords()
+	initBootstrapAdmin()
+	initMailer()
+	initLockout()
+	initRateLimit()
+	initOIDC()
+	initRegistration()
+	initCookies()
 
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	
-
 	allowedOrigins := []string{"http://localhost:5173"}
 	if envOrigins := os.Getenv("ALLOWED_ORIGINS"); envOrigins != "" {
 		origins := strings.Split(envOrigins, ",")
@@ -52,8 +61,8 @@ func Start() {
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", csrfHeader},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -66,53 +75,156 @@ func Start() {
 		WriteTimeout: timeout,
 		IdleTimeout:  120 * time.Second,
 	}
-		
+
 	// Health check (public)
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
-	// Auth routes (public)
-	router.POST("/api/auth/login", login)
-	router.POST("/api/auth/register", register)
-
-	// All routes below require authentication
+	// Public keys for services that verify our tokens (public)
+	router.GET("/.well-known/jwks.json", jwks)
+
+	// Auth routes (public). Ones that take a password or code are tightly
+	// limited per client IP.
+	auth := router.Group("/api/auth", RateLimit("auth", rateLimitFromEnv("RATE_LIMIT_AUTH", ratelimit.Every(10, time.Minute))))
+	auth.POST("/login", login)
+	auth.POST("/register", register)
+	auth.POST("/invite", checkInviteCode)
+	auth.POST("/forgot", forgotPassword)
+	auth.POST("/reset", resetPassword)
+	auth.POST("/login/2fa", loginTOTP)
+	auth.POST("/oidc/start", oidcStart)
+	auth.POST("/oidc/callback", oidcCallback)
+
+	// Every signed in browser refreshes its access token every 15 minutes,
+	// so a club sharing one IP would soon use up the login limit
+	tokens := router.Group("/api/auth", RateLimit("tokens", rateLimitFromEnv("RATE_LIMIT_TOKENS", ratelimit.Every(120, time.Minute))))
+	tokens.GET("/registration", registration)
+	tokens.POST("/refresh", refresh)
+	tokens.POST("/logout", logout)
+	tokens.POST("/verify", verifyEmail)
+
+	// All routes below require authentication and are limited per user
 	api := router.Group("/api")
-	api.Use(AuthMiddleware())
-
-	// Authenticated user info
-	api.GET("/auth/me", me)
+	api.Use(AuthMiddleware(), RequireCSRF(), RateLimit("api", rateLimitFromEnv("RATE_LIMIT_API", ratelimit.Every(120, time.Minute))))
+
+	// API keys can read with the read scope. Account management needs a
+	// real login.
+	read := api.Group("", RequireScope(db.ScopeRead))
+	session := api.Group("", RequireSession())
+
+	// Authenticated user profile
+	read.GET("/auth/me", me)
+	session.PATCH("/auth/me", updateMe)
+	session.DELETE("/auth/me", deleteMe)
+	session.POST("/auth/password", changePassword)
+	session.POST("/auth/verify/resend", resendVerification)
+
+	// Logged in devices
+	session.GET("/auth/sessions", getSessions)
+	session.DELETE("/auth/sessions", revokeAllSessions)
+	session.DELETE("/auth/sessions/:id", revokeSession)
+
+	// Two-factor enrollment is offered to the accounts that can change data
+	session.POST("/auth/2fa/setup", RequireRole(db.RoleAdmin, db.RoleCoach), setupTOTP)
+	session.POST("/auth/2fa/confirm", RequireRole(db.RoleAdmin, db.RoleCoach), confirmTOTP)
+	session.POST("/auth/2fa/disable", disableTOTP)
+
+	// API keys
+	session.POST("/apikeys", createAPIKey)
+	session.GET("/apikeys", getAPIKeys)
+	session.DELETE("/apikeys/:id", revokeAPIKey)
+
+	// Writes are limited to coaches and admins, everyone else can only read.
+	// With REQUIRE_VERIFIED_EMAIL set they also need a verified email.
+	write := api.Group("",
+		RateLimit("write", rateLimitFromEnv("RATE_LIMIT_WRITE", ratelimit.Every(30, time.Minute))),
+		RequireVerified(),
+		RequireRole(db.RoleAdmin, db.RoleCoach),
+	)
 
 	// Seed
-	api.POST("/seed", seed)
+	session.POST("/seed", RequireVerified(), RequireRole(db.RoleAdmin), seed)
 
 	// Player
-	api.GET("/player", getActivePlayers)
-	api.GET("/player/:id", getPlayerByID)
-	api.GET("/player/:id/fixtures", getPlayerFixtures)
-	api.POST("/player/add", addPlayer)
-	api.POST("/player/update", updatePlayer)
-	api.DELETE("/player/delete", deletePlayer)
+	read.GET("/player", getActivePlayers)
+	read.GET("/player/:id", getPlayerByID)
+	read.GET("/player/:id/fixtures", getPlayerFixtures)
+	read.GET("/player/:id/stats", getPlayerStats)
+	write.POST("/player/add", RequireScope(db.ScopePlayersWrite), addPlayer)
+	write.POST("/player/update", RequireScope(db.ScopePlayersWrite), updatePlayer)
+	write.DELETE("/player/delete", RequireScope(db.ScopePlayersWrite), deletePlayer)
+
+	// Linking accounts to players. Coaches approve claims for their team.
+	session.POST("/player/:id/claim", RequireVerified(), claimPlayer)
+	write.GET("/team/:id/claims", RequireScope(db.ScopeRead), getTeamPlayerClaims)
+	write.POST("/player/claims/:id/approve", RequireSession(), approvePlayerClaim)
+	write.POST("/player/claims/:id/reject", RequireSession(), rejectPlayerClaim)
+	read.GET("/me/player", getMyPlayer)
+	read.GET("/me/fixtures", getMyFixtures)
+	api.PATCH("/me/player", RequireScope(db.ScopePlayersWrite), RequireVerified(), updateMyPlayer)
 
 	// Teams
-	api.POST("/team/add", addTeam)
-	api.GET("/team/getbyid", getTeamById)
-	api.GET("/team/getidbyname", getTeamIdByName)
-	api.GET("/team/getall", getAllTeams)
+	write.POST("/team/add", RequireScope(db.ScopeTeamsWrite), addTeam)
+	read.GET("/team/getbyid", getTeamById)
+	read.GET("/team/getidbyname", getTeamIdByName)
+	read.GET("/team/getall", getAllTeams)
+	read.GET("/team/:id/fixtures", getTeamFixtures)
+	write.POST("/opponent/add", RequireScope(db.ScopeTeamsWrite), addOpponent)
+	read.GET("/opponent/getall", getOpponents)
+
+	// Seasons and competitions. Fixture, stat and leaderboard endpoints
+	// take ?season=&competition= to narrow their results.
+	write.POST("/season/add", RequireScope(db.ScopeFixturesWrite), addSeason)
+	read.GET("/season/getall", getSeasons)
+	write.POST("/competition/add", RequireScope(db.ScopeFixturesWrite), addCompetition)
+	read.GET("/competition/getall", getCompetitions)
+	session.GET("/team/invites", getMyInvites)
+	write.GET("/team/:id/members", RequireScope(db.ScopeRead), getTeamMembers)
+	write.POST("/team/:id/invite", RequireSession(), inviteToTeam)
+	write.POST("/team/:id/invite-codes", RequireSession(), createInviteCode)
+	write.GET("/team/:id/invite-codes", RequireSession(), getInviteCodes)
+	write.DELETE("/team/:id/invite-codes/:inviteId", RequireSession(), revokeInviteCode)
+	session.POST("/team/:id/accept", acceptInvite)
+	session.POST("/team/:id/decline", declineInvite)
 
 	// Fixtures
-	api.POST("/fixture/add", addFixture)
-	api.GET("/fixture/getall", getFixtures)
-	api.POST("/fixture/addgoalscorer", addGoalscorerToFixture)
-	api.POST("/fixture/addassist", addAssistToFixture)
-	api.POST("/fixture/addstat", addStatToFixture)
-	api.GET("/fixture/:id", getFixtureByID)
+	write.POST("/fixture/add", RequireScope(db.ScopeFixturesWrite), addFixture)
+	read.GET("/fixture/getall", getFixtures)
+	write.POST("/fixture/addgoalscorer", RequireScope(db.ScopeFixturesWrite), addGoalscorerToFixture)
+	write.POST("/fixture/addassist", RequireScope(db.ScopeFixturesWrite), addAssistToFixture)
+	write.POST("/fixture/addstat", RequireScope(db.ScopeFixturesWrite), addStatToFixture)
+	read.GET("/fixture/:id", getFixtureByID)
+	write.POST("/fixture/:id/start", RequireScope(db.ScopeFixturesWrite), startFixture)
+	write.POST("/fixture/:id/finish", RequireScope(db.ScopeFixturesWrite), finishFixture)
+	write.POST("/fixture/:id/postpone", RequireScope(db.ScopeFixturesWrite), postponeFixture)
+	write.POST("/fixture/:id/abandon", RequireScope(db.ScopeFixturesWrite), abandonFixture)
+	write.POST("/fixture/:id/motm", RequireScope(db.ScopeFixturesWrite), setManOfTheMatch)
+	write.POST("/fixture/:id/assign", RequireScope(db.ScopeFixturesWrite), assignFixture)
+	read.GET("/fixture/:id/events", getFixtureEvents)
+	write.POST("/fixture/:id/events", RequireScope(db.ScopeFixturesWrite), addFixtureEvent)
+	write.PUT("/fixture/:id/events/:eventId", RequireScope(db.ScopeFixturesWrite), updateFixtureEvent)
+	write.DELETE("/fixture/:id/events/:eventId", RequireScope(db.ScopeFixturesWrite), deleteFixtureEvent)
+
+	// Admin
+	admin := session.Group("/admin", RequireVerified(), RequireRole(db.RoleAdmin))
+	admin.GET("/users", listUsers)
+	admin.GET("/users/:id", getUser)
+	admin.PUT("/users/:id/role", setUserRole)
+	admin.POST("/users/:id/disable", disableUser)
+	admin.POST("/users/:id/enable", enableUser)
+	admin.POST("/users/:id/reset-password", forcePasswordReset)
+	admin.POST("/users/:id/impersonate", impersonateUser)
+	admin.POST("/users/:id/unlock", unlockUser)
+
+	// Audit log
+	session.GET("/audit", RequireVerified(), RequireRole(db.RoleAdmin), getAuditLog)
 
 	// Leaderboard
-	api.GET("/leaderboard/goals", leaderboardGoals)
-	api.GET("/leaderboard/assists", leaderboardAssists)
-	api.GET("/leaderboard/motm", leaderboardMotm)
-	api.GET("/leaderboard/fixtures", leaderboardFixtures)
+	read.GET("/leaderboard/goals", leaderboardGoals)
+	read.GET("/leaderboard/assists", leaderboardAssists)
+	read.GET("/leaderboard/motm", leaderboardMotm)
+	read.GET("/leaderboard/fixtures", leaderboardFixtures)
 
 	ln, err := net.Listen("tcp", ":"+port)
 	if err != nil {
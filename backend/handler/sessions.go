@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func getSessions(c *gin.Context) {
+	userID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+
+	sessions, err := db.GetUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sessions"})
+		return
+	}
+
+	current := c.GetString("sessionID")
+	results := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		results = append(results, gin.H{
+			"id":         session.ID.Hex(),
+			"user_agent": session.UserAgent,
+			"ip":         session.IP,
+			"last_seen":  session.LastSeen,
+			"created":    session.Created,
+			"current":    session.ID.Hex() == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": results})
+}
+
+func revokeSession(c *gin.Context) {
+	userID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+	sessionID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := db.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if cookiesEnabled && sessionID.Hex() == c.GetString("sessionID") {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// revokeAllSessions logs the user out everywhere, including this device.
+func revokeAllSessions(c *gin.Context) {
+	userID, _ := bson.ObjectIDFromHex(c.GetString("userID"))
+
+	if err := db.RevokeUserRefreshTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if cookiesEnabled {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out everywhere"})
+}
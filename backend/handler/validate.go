@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	maxScore   = 99
+)
+
+// parseDate accepts a calendar date such as 2025-03-01 or a full RFC 3339
+// timestamp when the kick-off time is known.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("dates must look like 2025-03-01 or 2025-03-01T15:00:00Z")
+}
+
+// parseDOB parses a date of birth, which has to be in the past.
+func parseDOB(value string) (time.Time, error) {
+	dob, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date of birth must look like 2001-09-30")
+	}
+	if dob.After(time.Now()) || dob.Year() < 1900 {
+		return time.Time{}, fmt.Errorf("date of birth is not plausible")
+	}
+	return dob, nil
+}
+
+// parseScore parses an optional score. Empty means no score yet.
+func parseScore(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	score, err := strconv.Atoi(value)
+	if err != nil || score < 0 || score > maxScore {
+		return nil, fmt.Errorf("scores must be whole numbers between 0 and %d", maxScore)
+	}
+	return &score, nil
+}
+
+// parseFounded parses an optional founding year. Empty gives 0.
+func parseFounded(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	year, err := strconv.Atoi(value)
+	if err != nil || year < 1850 || year > time.Now().Year() {
+		return 0, fmt.Errorf("founded must be a year no later than this one")
+	}
+	return year, nil
+}
+
+// parseCount parses a stat counter such as goals.
+func parseCount(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("counts must be whole numbers of zero or more")
+	}
+	return n, nil
+}
+
+// text stores a value as given.
+func text(value string) (any, error) {
+	return value, nil
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"fctracker/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func sendVerificationEmail(c *gin.Context, user db.User) error {
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.CreateUserToken(c.Request.Context(), user.ID, db.TokenPurposeEmailVerification, tokenHash, time.Now().Add(emailVerificationTTL))
+	if err != nil {
+		return err
+	}
+
+	link := frontendURL() + "/verify-email?token=" + token
+	sendMail(user.Email, "Confirm your FC Tracker email", fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm this is your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in 48 hours.",
+		user.Name, link,
+	))
+	return nil
+}
+
+func verifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+		return
+	}
+
+	token, err := db.ConsumeUserToken(c.Request.Context(), db.TokenPurposeEmailVerification, hashToken(req.Token))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification link is invalid or has expired"})
+		return
+	}
+
+	if err := db.SetUserVerified(c.Request.Context(), token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+func resendVerification(c *gin.Context) {
+	user, err := db.GetUserByID(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Verified {
+		c.JSON(http.StatusOK, gin.H{"message": "Email is already verified"})
+		return
+	}
+
+	if err := sendVerificationEmail(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
@@ -0,0 +1,183 @@
+// Package keyring signs and verifies JWTs with a set of keys identified by
+// the kid header, so signing keys can be rotated without invalidating
+// tokens signed by the previous key.
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one signing or verification key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	sign   any // nil for keys that only verify
+	verify any
+}
+
+// NewHMACKey returns an HS256 key. Its ID is derived from the secret so the
+// same secret always gets the same kid.
+func NewHMACKey(secret []byte) *Key {
+	sum := sha256.Sum256(append([]byte("hmac:"), secret...))
+	return &Key{
+		ID:     hex.EncodeToString(sum[:8]),
+		Method: jwt.SigningMethodHS256,
+		sign:   secret,
+		verify: secret,
+	}
+}
+
+// ParsePrivateKey reads an RSA (RS256) or Ed25519 (EdDSA) private key in PEM.
+func ParsePrivateKey(pemData []byte) (*Key, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pemData); err == nil {
+		return newPublicKey(jwt.SigningMethodRS256, key, &key.PublicKey)
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(pemData); err == nil {
+		return newPublicKey(jwt.SigningMethodEdDSA, key, key.(ed25519.PrivateKey).Public())
+	}
+	return nil, fmt.Errorf("not an RSA or Ed25519 private key")
+}
+
+// ParsePublicKey reads an RSA or Ed25519 public key in PEM. It is used for
+// retired signing keys whose tokens should still verify.
+func ParsePublicKey(pemData []byte) (*Key, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pemData); err == nil {
+		return newPublicKey(jwt.SigningMethodRS256, nil, key)
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(pemData); err == nil {
+		return newPublicKey(jwt.SigningMethodEdDSA, nil, key)
+	}
+	return nil, fmt.Errorf("not an RSA or Ed25519 public key")
+}
+
+// newPublicKey derives the kid from the public key, so every service sees the
+// same ID for it.
+func newPublicKey(method jwt.SigningMethod, private any, public crypto.PublicKey) (*Key, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &Key{
+		ID:     base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method: method,
+		sign:   private,
+		verify: public,
+	}, nil
+}
+
+// Keyring signs with one key and verifies with any of its keys.
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+
+	// Tokens from before key IDs existed have no kid and are checked
+	// against this key
+	fallback *Key
+}
+
+// New creates a keyring that signs with signing. The other keys are only
+// used to verify tokens.
+func New(signing *Key, verifyOnly ...*Key) (*Keyring, error) {
+	if signing.sign == nil {
+		return nil, fmt.Errorf("signing key %s has no private key", signing.ID)
+	}
+
+	k := &Keyring{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verifyOnly {
+		k.keys[key.ID] = key
+	}
+	return k, nil
+}
+
+// SetFallback sets the key used for tokens without a kid header.
+func (k *Keyring) SetFallback(key *Key) {
+	k.fallback = key
+}
+
+// Sign returns the claims as a token signed by the signing key.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.sign)
+}
+
+// Keyfunc picks the key named by the token's kid and makes sure the token
+// uses that key's algorithm. Pass it to jwt.Parse.
+func (k *Keyring) Keyfunc(token *jwt.Token) (any, error) {
+	var key *Key
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = k.keys[kid]
+	} else {
+		key = k.fallback
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.verify, nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS lists the public keys. HMAC secrets are never published, so a
+// keyring with only HMAC keys has an empty set.
+func (k *Keyring) JWKS() []JWK {
+	keys := []JWK{}
+	for _, key := range k.keys {
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	slices.SortFunc(keys, func(a, b JWK) int { return strings.Compare(a.Kid, b.Kid) })
+	return keys
+}
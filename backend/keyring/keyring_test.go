@@ -0,0 +1,276 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RSA keys are slow to generate, so the tests share one
+var rsaKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// privateKey parses the key from PEM, as it is read from the environment.
+func privateKey(t *testing.T, key crypto.PrivateKey) *Key {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func publicKey(t *testing.T, key crypto.PublicKey) *Key {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func newEd25519(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newKeyring(t *testing.T, signing *Key, verifyOnly ...*Key) *Keyring {
+	t.Helper()
+	k, err := New(signing, verifyOnly...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func parse(k *Keyring, token string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, k.Keyfunc)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *Key
+		alg  string
+	}{
+		{"hmac", NewHMACKey([]byte("secret")), "HS256"},
+		{"rsa", privateKey(t, rsaKey()), "RS256"},
+		{"ed25519", privateKey(t, newEd25519(t)), "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newKeyring(t, tt.key)
+			signed, err := k.Sign(jwt.RegisteredClaims{Subject: "user-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := parse(k, signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Header["kid"] != tt.key.ID || token.Method.Alg() != tt.alg {
+				t.Errorf("header = %v, want kid %s and alg %s", token.Header, tt.key.ID, tt.alg)
+			}
+			if sub, _ := token.Claims.GetSubject(); sub != "user-1" {
+				t.Errorf("subject = %q, want user-1", sub)
+			}
+		})
+	}
+}
+
+// After a rotation tokens signed by the old key still verify against its
+// public key.
+func TestVerifyWithRetiredKey(t *testing.T) {
+	old := newKeyring(t, privateKey(t, rsaKey()))
+	signed, err := old.Sign(jwt.RegisteredClaims{Subject: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newKeyring(t, privateKey(t, newEd25519(t)), publicKey(t, &rsaKey().PublicKey))
+	if _, err := parse(rotated, signed); err != nil {
+		t.Fatalf("token from the retired key: %v", err)
+	}
+
+	// Once the old key is dropped its tokens stop working
+	dropped := newKeyring(t, privateKey(t, newEd25519(t)))
+	if _, err := parse(dropped, signed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("token from a dropped key: %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRejectsUnknownKid(t *testing.T) {
+	k := newKeyring(t, NewHMACKey([]byte("secret")))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user-1"})
+	token.Header["kid"] = "not-a-key"
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parse(k, signed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unknown kid: %v, want ErrUnknownKey", err)
+	}
+}
+
+// A token has to use the algorithm of the key its kid names, or an RSA
+// public key could be used as an HMAC secret.
+func TestRejectsAlgMismatch(t *testing.T) {
+	rsaSigning := privateKey(t, rsaKey())
+	k := newKeyring(t, rsaSigning)
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey().PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user-1"})
+	hmac.Header["kid"] = rsaSigning.ID
+	forged, err := hmac.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(k, forged); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("HS256 token for an RSA key: %v, want ErrTokenSignatureInvalid", err)
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{Subject: "user-1"})
+	none.Header["kid"] = rsaSigning.ID
+	unsigned, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(k, unsigned); err == nil {
+		t.Error("unsigned token verified")
+	}
+}
+
+// Tokens issued before kids existed verify against the fallback key only.
+func TestFallbackForLegacyTokens(t *testing.T) {
+	legacySecret := []byte("legacy-secret")
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user-1"}).SignedString(legacySecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := newKeyring(t, privateKey(t, newEd25519(t)))
+	if _, err := parse(k, legacy); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("kid-less token without a fallback: %v, want ErrUnknownKey", err)
+	}
+
+	k.SetFallback(NewHMACKey(legacySecret))
+	if _, err := parse(k, legacy); err != nil {
+		t.Fatalf("kid-less token with a fallback: %v", err)
+	}
+
+	wrongSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user-1"}).SignedString([]byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(k, wrongSecret); err == nil {
+		t.Error("kid-less token signed with another secret verified")
+	}
+
+	rsaLegacy, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{Subject: "user-1"}).SignedString(rsaKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(k, rsaLegacy); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("kid-less RS256 token for an HMAC fallback: %v, want ErrTokenSignatureInvalid", err)
+	}
+}
+
+func TestNewNeedsPrivateKey(t *testing.T) {
+	if _, err := New(publicKey(t, newEd25519(t).Public())); err == nil {
+		t.Fatal("signed with a public key")
+	}
+}
+
+func TestKeyIDs(t *testing.T) {
+	if NewHMACKey([]byte("a")).ID != NewHMACKey([]byte("a")).ID {
+		t.Error("same secret got different kids")
+	}
+	if NewHMACKey([]byte("a")).ID == NewHMACKey([]byte("b")).ID {
+		t.Error("different secrets got the same kid")
+	}
+	if privateKey(t, rsaKey()).ID != publicKey(t, &rsaKey().PublicKey).ID {
+		t.Error("private and public halves of a key got different kids")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	edKey := newEd25519(t)
+	hmacKey := NewHMACKey([]byte("secret"))
+	rsaSigning := privateKey(t, rsaKey())
+	edPublic := publicKey(t, edKey.Public())
+	k := newKeyring(t, rsaSigning, edPublic, hmacKey)
+
+	keys := k.JWKS()
+	if len(keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want the RSA and Ed25519 ones: %+v", len(keys), keys)
+	}
+	if keys[0].Kid > keys[1].Kid {
+		t.Error("keys aren't sorted by kid")
+	}
+
+	byKid := map[string]JWK{}
+	for _, key := range keys {
+		if key.Kid == hmacKey.ID {
+			t.Fatal("HMAC key was published")
+		}
+		if key.Use != "sig" {
+			t.Errorf("%s use = %q, want sig", key.Kid, key.Use)
+		}
+		byKid[key.Kid] = key
+	}
+
+	rsaJWK := byKid[rsaSigning.ID]
+	modulus := base64.RawURLEncoding.EncodeToString(rsaKey().N.Bytes())
+	if rsaJWK.Kty != "RSA" || rsaJWK.Alg != "RS256" || rsaJWK.N != modulus || rsaJWK.E != "AQAB" {
+		t.Errorf("RSA key = %+v", rsaJWK)
+	}
+
+	edJWK := byKid[edPublic.ID]
+	x := base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey))
+	if edJWK.Kty != "OKP" || edJWK.Alg != "EdDSA" || edJWK.Crv != "Ed25519" || edJWK.X != x {
+		t.Errorf("Ed25519 key = %+v", edJWK)
+	}
+
+	// An HMAC-only keyring publishes an empty set, not null
+	data, err := json.Marshal(newKeyring(t, hmacKey).JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("HMAC-only JWKS = %s, want []", data)
+	}
+}
@@ -0,0 +1,163 @@
+// Package lockout slows down and temporarily locks out repeated failed
+// logins. Failures are counted per key, for example an account or a client
+// IP, in a pluggable Store.
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Record is the failure history for one key.
+type Record struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until,omitempty"`
+}
+
+// Store persists failure records. Get returns a zero Record for unknown keys.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	AddFailure(ctx context.Context, key string, at time.Time) (Record, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy describes how quickly failures are punished.
+type Policy struct {
+	FreeAttempts  int           // Failures allowed before any delay
+	BaseDelay     time.Duration // Delay after the first failure past FreeAttempts, doubled for each one after
+	MaxDelay      time.Duration
+	LockThreshold int // Failures that trigger a lockout
+	LockDuration  time.Duration
+	ForgetAfter   time.Duration // Quiet period after which failures are forgotten
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+}
+
+func New(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy}
+}
+
+// delay is the backoff owed after the given number of failures.
+func (p Policy) delay(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < over; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+func (l *Limiter) stale(r Record, now time.Time) bool {
+	return r.Failures > 0 && now.Sub(r.LastFailure) > l.policy.ForgetAfter && !now.Before(r.LockedUntil)
+}
+
+// Wait returns how long the caller has to wait before key may try again.
+func (l *Limiter) Wait(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	r, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if r.Failures == 0 || l.stale(r, now) {
+		return 0, nil
+	}
+
+	next := r.LastFailure.Add(l.policy.delay(r.Failures))
+	if r.LockedUntil.After(next) {
+		next = r.LockedUntil
+	}
+	if now.Before(next) {
+		return next.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt for key and locks it once the threshold is
+// reached. It reports whether the key is now locked.
+func (l *Limiter) Fail(ctx context.Context, key string, now time.Time) (bool, error) {
+	r, err := l.store.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if l.stale(r, now) {
+		if err := l.store.Reset(ctx, key); err != nil {
+			return false, err
+		}
+	}
+
+	r, err = l.store.AddFailure(ctx, key, now)
+	if err != nil {
+		return false, err
+	}
+
+	if l.policy.LockThreshold > 0 && r.Failures >= l.policy.LockThreshold && r.Failures%l.policy.LockThreshold == 0 {
+		return true, l.store.Lock(ctx, key, now.Add(l.policy.LockDuration))
+	}
+	return false, nil
+}
+
+// Reset forgets every failure for key, after a successful login or when an
+// admin unlocks it.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+// MemoryStore keeps records in process. It is meant for tests and single
+// instance development setups.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[key], nil
+}
+
+func (m *MemoryStore) AddFailure(ctx context.Context, key string, at time.Time) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := m.records[key]
+	r.Key = key
+	r.Failures++
+	r.LastFailure = at
+	m.records[key] = r
+	return r, nil
+}
+
+func (m *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := m.records[key]
+	r.Key = key
+	r.LockedUntil = until
+	m.records[key] = r
+	return nil
+}
+
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
@@ -0,0 +1,215 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:  3,
+	BaseDelay:     time.Second,
+	MaxDelay:      8 * time.Second,
+	LockThreshold: 5,
+	LockDuration:  15 * time.Minute,
+	ForgetAfter:   time.Hour,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 8 * time.Second},
+		{100, 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := testPolicy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterBackoff(t *testing.T) {
+	ctx := context.Background()
+	l := New(NewMemoryStore(), testPolicy)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	wait := func(at time.Time) time.Duration {
+		t.Helper()
+		d, err := l.Wait(ctx, "user@example.com", at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	fail := func(at time.Time) bool {
+		t.Helper()
+		locked, err := l.Fail(ctx, "user@example.com", at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return locked
+	}
+
+	if d := wait(now); d != 0 {
+		t.Fatalf("unknown key has to wait %v", d)
+	}
+
+	// Free attempts cost nothing
+	for i := 0; i < testPolicy.FreeAttempts; i++ {
+		if fail(now) {
+			t.Fatalf("locked after %d failures", i+1)
+		}
+	}
+	if d := wait(now); d != 0 {
+		t.Fatalf("wait after free attempts = %v, want 0", d)
+	}
+
+	// Then each failure doubles the delay
+	fail(now)
+	if d := wait(now); d != time.Second {
+		t.Fatalf("wait after 4 failures = %v, want 1s", d)
+	}
+	if d := wait(now.Add(400 * time.Millisecond)); d != 600*time.Millisecond {
+		t.Fatalf("wait is not measured from the last failure: %v", d)
+	}
+	if d := wait(now.Add(time.Second)); d != 0 {
+		t.Fatalf("wait once the delay has passed = %v, want 0", d)
+	}
+
+	// Reaching the threshold locks the key
+	if !fail(now) {
+		t.Fatal("not locked at the threshold")
+	}
+	if d := wait(now); d != testPolicy.LockDuration {
+		t.Fatalf("wait when locked = %v, want %v", d, testPolicy.LockDuration)
+	}
+	if d := wait(now.Add(testPolicy.LockDuration)); d != 0 {
+		t.Fatalf("wait after the lock expired = %v, want 0", d)
+	}
+
+	if err := l.Reset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if d := wait(now); d != 0 {
+		t.Fatalf("wait after reset = %v, want 0", d)
+	}
+}
+
+func TestLimiterRelocksEveryThreshold(t *testing.T) {
+	ctx := context.Background()
+	l := New(NewMemoryStore(), testPolicy)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var locks []int
+	for i := 1; i <= 3*testPolicy.LockThreshold; i++ {
+		locked, err := l.Fail(ctx, "ip:203.0.113.7", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked {
+			locks = append(locks, i)
+		}
+	}
+
+	want := []int{5, 10, 15}
+	if len(locks) != len(want) {
+		t.Fatalf("locked after failures %v, want %v", locks, want)
+	}
+	for i := range want {
+		if locks[i] != want[i] {
+			t.Fatalf("locked after failures %v, want %v", locks, want)
+		}
+	}
+}
+
+func TestLimiterForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	l := New(store, testPolicy)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < testPolicy.LockThreshold-1; i++ {
+		if _, err := l.Fail(ctx, "key", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	later := now.Add(testPolicy.ForgetAfter + time.Minute)
+	if d, err := l.Wait(ctx, "key", later); err != nil || d != 0 {
+		t.Fatalf("wait after the quiet period = %v, %v", d, err)
+	}
+
+	// The next failure starts a fresh count instead of locking
+	locked, err := l.Fail(ctx, "key", later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Fatal("old failures counted towards a lock")
+	}
+	r, err := store.Get(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Failures != 1 {
+		t.Fatalf("failures = %d, want 1", r.Failures)
+	}
+}
+
+func TestLimiterKeepsLockPastQuietPeriod(t *testing.T) {
+	ctx := context.Background()
+	policy := testPolicy
+	policy.ForgetAfter = time.Minute
+	l := New(NewMemoryStore(), policy)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < policy.LockThreshold; i++ {
+		if _, err := l.Fail(ctx, "key", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	at := now.Add(2 * time.Minute)
+	d, err := l.Wait(ctx, "key", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := policy.LockDuration - 2*time.Minute; d != want {
+		t.Fatalf("wait = %v, want %v while still locked", d, want)
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+
+	if _, err := store.AddFailure(ctx, "a", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Lock(ctx, "a", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := store.Get(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Failures != 0 || !r.LockedUntil.IsZero() {
+		t.Fatalf("unrelated key has record %+v", r)
+	}
+
+	r, _ = store.Get(ctx, "a")
+	if r.Key != "a" || r.Failures != 1 || !r.LockedUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("record = %+v", r)
+	}
+}
@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// New builds the mailer selected by MAIL_DRIVER. "smtp" sends real mail,
+// anything else falls back to the log mailer.
+func New() Mailer {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	default:
+		return &LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+	}
+}
+
+// SMTPMailer delivers mail through an SMTP relay using PLAIN auth.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer writes mail to a file, or to the log when no path is set. It is
+// meant for local development and tests.
+type LogMailer struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	entry := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n---\n", to, subject, body)
+
+	if m.Path == "" {
+		log.Printf("Mail not sent (log mailer):\n%s", entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
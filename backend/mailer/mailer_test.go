@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewSelectsDriver(t *testing.T) {
+	t.Setenv("MAIL_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("MAIL_FROM", "noreply@example.com")
+
+	m, ok := New().(*SMTPMailer)
+	if !ok {
+		t.Fatal("MAIL_DRIVER=smtp didn't give an SMTP mailer")
+	}
+	if m.Host != "smtp.example.com" || m.Port != "587" || m.From != "noreply@example.com" {
+		t.Errorf("SMTP mailer = %+v", m)
+	}
+
+	t.Setenv("MAIL_DRIVER", "")
+	t.Setenv("MAIL_LOG_FILE", "/tmp/mail.log")
+	l, ok := New().(*LogMailer)
+	if !ok {
+		t.Fatal("default driver isn't the log mailer")
+	}
+	if l.Path != "/tmp/mail.log" {
+		t.Errorf("log mailer path = %q", l.Path)
+	}
+}
+
+func TestLogMailerAppendsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m := &LogMailer{Path: path}
+
+	if err := m.Send("a@example.com", "Reset your password", "Use this link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send("b@example.com", "Verify your email", "Use this code"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := strings.Split(strings.TrimSuffix(string(data), "---\n"), "---\n")
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2:\n%s", len(entries), data)
+	}
+	for i, want := range []string{
+		"To: a@example.com\nSubject: Reset your password\n\nUse this link\n",
+		"To: b@example.com\nSubject: Verify your email\n\nUse this code\n",
+	} {
+		if entries[i] != want {
+			t.Errorf("entry %d = %q, want %q", i, entries[i], want)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("mail log permissions = %o, want 600 as it holds reset links", perm)
+	}
+}
+
+func TestLogMailerWithoutPath(t *testing.T) {
+	if err := (&LogMailer{}).Send("a@example.com", "Subject", "Body"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSMTPMailerRejectsHeaderInjection(t *testing.T) {
+	m := &SMTPMailer{Host: "127.0.0.1", Port: "0", From: "noreply@example.com"}
+
+	tests := []struct {
+		name, to, subject string
+	}{
+		{"newline in recipient", "a@example.com\r\nBcc: b@example.com", "Hello"},
+		{"newline in subject", "a@example.com", "Hello\nBcc: b@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Send(tt.to, tt.subject, "Body")
+			if err == nil || err.Error() != "invalid mail header" {
+				t.Errorf("Send = %v, want invalid mail header", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Package passwords hashes passwords and decides which ones are acceptable.
+// New hashes use argon2id. bcrypt hashes from older accounts still verify
+// and are reported as needing a rehash so they can be upgraded on login.
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownFormat = errors.New("unrecognised password hash format")
+
+// Params are the argon2id cost settings.
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the second recommended option of RFC 9106.
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func (p Params) validate() error {
+	if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 {
+		return fmt.Errorf("argon2id needs at least 1 iteration, 1 thread and 8 KiB of memory per thread")
+	}
+	if p.SaltLength < 8 || p.KeyLength < 16 {
+		return fmt.Errorf("argon2id salt must be at least 8 bytes and the key at least 16")
+	}
+	return nil
+}
+
+// Hasher creates argon2id hashes with one set of parameters.
+type Hasher struct {
+	params Params
+}
+
+func NewHasher(params Params) (*Hasher, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	return &Hasher{params: params}, nil
+}
+
+// Hash returns the password as an encoded argon2id hash in the PHC string
+// format, for example $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether password matches the encoded hash, and whether the
+// hash should be replaced because it uses bcrypt or outdated parameters.
+func (h *Hasher) Verify(encoded, password string) (ok, rehash bool, err error) {
+	switch {
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false, nil
+		}
+		return true, params != h.params, nil
+	}
+
+	return false, false, ErrUnknownFormat
+}
+
+func isBcrypt(encoded string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeArgon2id(encoded string) (Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+	if version != argon2.Version {
+		return Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var p Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	if err := p.validate(); err != nil {
+		return Params{}, nil, nil, err
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,155 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters so the tests stay fast
+var testParams = Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func newTestHasher(t *testing.T, params Params) *Hasher {
+	t.Helper()
+	h, err := NewHasher(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNewHasherValidatesParams(t *testing.T) {
+	if _, err := NewHasher(DefaultParams); err != nil {
+		t.Fatalf("default params rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Params)
+	}{
+		{"no iterations", func(p *Params) { p.Iterations = 0 }},
+		{"no threads", func(p *Params) { p.Parallelism = 0 }},
+		{"too little memory per thread", func(p *Params) { p.Memory = 8*uint32(p.Parallelism) - 1 }},
+		{"short salt", func(p *Params) { p.SaltLength = 7 }},
+		{"short key", func(p *Params) { p.KeyLength = 15 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams
+			params.Parallelism = 2
+			params.Memory = 16
+			tt.modify(&params)
+			if h, err := NewHasher(params); err == nil || h != nil {
+				t.Errorf("NewHasher(%+v) = %v, %v, want an error", params, h, err)
+			}
+		})
+	}
+}
+
+func TestHashFormat(t *testing.T) {
+	h := newTestHasher(t, testParams)
+
+	encoded, err := h.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash = %s, want a PHC argon2id string with the params", encoded)
+	}
+
+	other, err := h.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded == other {
+		t.Error("two hashes of the same password are equal, salt isn't random")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	h := newTestHasher(t, testParams)
+	argon, err := h.Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+	outdated, err := newTestHasher(t, stronger).Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		encoded    string
+		password   string
+		wantOK     bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{"argon2id match", argon, "s3cret-pass", true, false, nil},
+		{"argon2id mismatch", argon, "wrong", false, false, nil},
+		{"argon2id with other params", outdated, "s3cret-pass", true, true, nil},
+		{"argon2id with other params mismatch", outdated, "wrong", false, false, nil},
+		{"bcrypt match is upgraded", string(bcryptHash), "s3cret-pass", true, true, nil},
+		{"bcrypt mismatch", string(bcryptHash), "wrong", false, false, nil},
+		{"bcrypt 2y prefix", "$2y$" + string(bcryptHash)[4:], "s3cret-pass", true, true, nil},
+		{"plain text", "s3cret-pass", "s3cret-pass", false, false, ErrUnknownFormat},
+		{"empty", "", "", false, false, ErrUnknownFormat},
+		{"argon2id missing parts", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA", "x", false, false, ErrUnknownFormat},
+		{"argon2id bad params", "$argon2id$v=19$m=x$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5", "x", false, false, ErrUnknownFormat},
+		{"argon2id bad salt", "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5a2V5a2V5a2V5a2V5", "x", false, false, ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := h.Verify(tt.encoded, tt.password)
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf("Verify = %v, %v, want %v, %v", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsOtherArgonVersions(t *testing.T) {
+	h := newTestHasher(t, testParams)
+	encoded, err := h.Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, _, err := h.Verify(strings.Replace(encoded, "v=19", "v=16", 1), "s3cret-pass")
+	if ok || err == nil {
+		t.Fatalf("Verify accepted argon2 version 16: %v, %v", ok, err)
+	}
+}
+
+func TestVerifyRejectsWeakStoredParams(t *testing.T) {
+	h := newTestHasher(t, testParams)
+
+	// A tampered hash asking for no work at all
+	ok, _, err := h.Verify("$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U", "x")
+	if ok || err == nil {
+		t.Fatalf("Verify accepted zero iterations: %v, %v", ok, err)
+	}
+}
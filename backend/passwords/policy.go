@@ -0,0 +1,91 @@
+package passwords
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Passwords that show up at the top of every breach corpus. Deployments
+// can add a much larger list with LoadBlocklist.
+//
+//go:embed common.txt
+var commonPasswords string
+
+// Policy decides whether a new password is acceptable.
+type Policy struct {
+	MinLength int
+	MaxLength int // Keeps hashing cost bounded
+
+	blocklist map[string]struct{}
+}
+
+// NewPolicy returns a policy with the built-in blocklist.
+func NewPolicy(minLength, maxLength int) *Policy {
+	p := &Policy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		blocklist: map[string]struct{}{},
+	}
+	p.addBlocklist(strings.NewReader(commonPasswords))
+	return p
+}
+
+// LoadBlocklist adds the passwords in a file, one per line, to the
+// blocklist. Matching ignores case.
+func (p *Policy) LoadBlocklist(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return p.addBlocklist(f)
+}
+
+func (p *Policy) addBlocklist(r io.Reader) (int, error) {
+	added := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, ok := p.blocklist[line]; !ok {
+			p.blocklist[line] = struct{}{}
+			added++
+		}
+	}
+	return added, scanner.Err()
+}
+
+// Check returns an error suitable for showing to the user when the password
+// is not acceptable. Personal details such as the user's name and email
+// can't be used as the password either.
+func (p *Policy) Check(password string, personal ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("Password must be at most %d characters", p.MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.blocklist[lower]; ok {
+		return fmt.Errorf("This password is too common. Please choose another.")
+	}
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if lower == value || lower == strings.Split(value, "@")[0] {
+			return fmt.Errorf("Password must not be your name or email")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p := NewPolicy(8, 16)
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		wantErr  bool
+	}{
+		{"acceptable", "kettle-violin-42", nil, false},
+		{"exactly the minimum", "kettle42", nil, false},
+		{"too short", "kettle4", nil, true},
+		{"too long", "kettle-violin-42!", nil, true},
+		{"length counts characters not bytes", "üüüüüüüüüü", nil, false},
+		{"common password", "password123", nil, true},
+		{"common password ignores case", "PassWord123", nil, true},
+		{"user's name", "Jamie Smith", []string{"jamie smith", "jamie@example.com"}, true},
+		{"user's email", "jamie@example.com", []string{"Jamie Smith", "jamie@example.com"}, true},
+		{"email local part", "JAMIE.SMITH", []string{"", "jamie.smith@example.com"}, true},
+		{"contains name but isn't it", "jamie-kettle-42", []string{"jamie"}, false},
+		{"empty personal values are skipped", "kettle-violin-42", []string{"", "  "}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.password, tt.personal...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%q) = %v, want error %v", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyWithoutMaxLength(t *testing.T) {
+	p := NewPolicy(8, 0)
+	if err := p.Check("a-rather-long-passphrase-with-no-upper-limit-at-all"); err != nil {
+		t.Fatalf("Check = %v, want no maximum", err)
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	list := "# Breached passwords\n\nKettleViolin42\n  swordfish-99  \npassword123\nkettleviolin42\n"
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPolicy(8, 128)
+	n, err := p.LoadBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// password123 is built in and kettleviolin42 is a duplicate
+	if n != 2 {
+		t.Errorf("added %d passwords, want 2", n)
+	}
+
+	for _, pw := range []string{"kettleviolin42", "SWORDFISH-99"} {
+		if err := p.Check(pw); err == nil {
+			t.Errorf("Check(%q) passed, want it blocked", pw)
+		}
+	}
+
+	if _, err := p.LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBlocklist of a missing file didn't fail")
+	}
+}
@@ -0,0 +1,114 @@
+// Package ratelimit implements token bucket rate limiting over a pluggable
+// Store.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled at Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Every builds a Limit of n requests per interval, all of which may be used
+// at once.
+func Every(n int, interval time.Duration) Limit {
+	return Limit{Rate: float64(n) / interval.Seconds(), Burst: n}
+}
+
+// ParseLimit reads limits written as "<requests>/<interval>", for example
+// "10/1m" or "120/m". A bare unit means one of it.
+func ParseLimit(s string) (Limit, error) {
+	count, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	interval, err := time.ParseDuration(per)
+	if err != nil || interval <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	return Every(n, interval), nil
+}
+
+// Store keeps one bucket per key. Take removes a token from the key's bucket
+// and reports how long to wait when there is none.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	refill time.Duration // Time an empty bucket takes to fill up
+}
+
+// MemoryStore keeps buckets in process. Limits are per instance, which is
+// fine for our single server.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+const sweepInterval = 5 * time.Minute
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		m.buckets[key] = b
+	}
+	b.refill = time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+
+	b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+	if b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.last) > b.refill {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Limit
+	}{
+		{"10/1m", Limit{Rate: 10.0 / 60, Burst: 10}},
+		{"120/m", Limit{Rate: 2, Burst: 120}},
+		{" 5/1s ", Limit{Rate: 5, Burst: 5}},
+		{"30/30s", Limit{Rate: 1, Burst: 30}},
+		{"1/h", Limit{Rate: 1.0 / 3600, Burst: 1}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.spec)
+		if err != nil {
+			t.Errorf("ParseLimit(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimitInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"10",
+		"/m",
+		"0/m",
+		"-1/m",
+		"ten/m",
+		"10/",
+		"10/0s",
+		"10/-1m",
+		"10/fortnight",
+	} {
+		if limit, err := ParseLimit(spec); err == nil {
+			t.Errorf("ParseLimit(%q) = %+v, want an error", spec, limit)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	if got, want := Every(60, time.Minute), (Limit{Rate: 1, Burst: 60}); got != want {
+		t.Fatalf("Every(60, time.Minute) = %+v, want %+v", got, want)
+	}
+}
+
+func TestMemoryStoreDrainAndRefill(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	limit := Limit{Rate: 2, Burst: 3}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	take := func(key string, at time.Time) (bool, time.Duration) {
+		t.Helper()
+		allowed, wait, err := m.Take(ctx, key, limit, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allowed, wait
+	}
+
+	// A new bucket starts full
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _ := take("a", now); !allowed {
+			t.Fatalf("request %d refused from a full bucket", i+1)
+		}
+	}
+
+	// Then has to wait for a token to drip back in
+	if allowed, wait := take("a", now); allowed || wait != 500*time.Millisecond {
+		t.Fatalf("empty bucket = %v, wait %v, want refused with 500ms", allowed, wait)
+	}
+	if allowed, wait := take("a", now.Add(250*time.Millisecond)); allowed || wait != 250*time.Millisecond {
+		t.Fatalf("half a token = %v, wait %v, want refused with 250ms", allowed, wait)
+	}
+	if allowed, _ := take("a", now.Add(500*time.Millisecond)); !allowed {
+		t.Fatal("refused once a token refilled")
+	}
+
+	// Other keys have their own bucket
+	if allowed, _ := take("b", now); !allowed {
+		t.Fatal("another key shares the bucket")
+	}
+
+	// An idle bucket fills up to the burst and no further
+	later := now.Add(time.Hour)
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _ := take("a", later); !allowed {
+			t.Fatalf("request %d refused after refilling", i+1)
+		}
+	}
+	if allowed, _ := take("a", later); allowed {
+		t.Fatal("bucket refilled past its burst")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	limit := Every(10, time.Minute)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, key := range []string{"a", "b"} {
+		if _, _, err := m.Take(ctx, key, limit, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "a" stays busy, "b" has been idle long enough to be full again
+	later := now.Add(sweepInterval + time.Second)
+	if _, _, err := m.Take(ctx, "a", limit, later.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Take(ctx, "c", limit, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.buckets["b"]; ok {
+		t.Error("idle bucket wasn't swept")
+	}
+	if _, ok := m.buckets["a"]; !ok {
+		t.Error("busy bucket was swept")
+	}
+}
@@ -0,0 +1,138 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 seed from RFC 6238 appendix B, "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC 6238 SHA-1 test vectors, cut to our 6 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeAtRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := CodeAt(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt(%d): %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("CodeAt(%d) = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestCodeAtLowercaseSecret(t *testing.T) {
+	code, err := CodeAt(strings.ToLower(rfcSecret), Step(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Fatalf("CodeAt with lowercase secret = %q, %v", code, err)
+	}
+}
+
+func TestCodeAtInvalidSecret(t *testing.T) {
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Fatal("CodeAt accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	codeAt := func(s int64) string {
+		code, err := CodeAt(rfcSecret, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", codeAt(step), step, true},
+		{"previous step", codeAt(step - 1), step - 1, true},
+		{"next step", codeAt(step + 1), step + 1, true},
+		{"surrounding whitespace", " " + codeAt(step) + "\n", step, true},
+		{"two steps old", codeAt(step - 2), 0, false},
+		{"two steps ahead", codeAt(step + 2), 0, false},
+		{"wrong code", "000000", 0, false},
+		{"too short", codeAt(step)[:5], 0, false},
+		{"too long", codeAt(step) + "0", 0, false},
+		{"empty", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a) != 32 {
+		t.Errorf("secret length = %d, want 32 base32 characters", len(a))
+	}
+	if a == b {
+		t.Error("two generated secrets are equal")
+	}
+	if _, err := CodeAt(a, 1); err != nil {
+		t.Errorf("generated secret is unusable: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("FC Tracker", "coach@example.com", rfcSecret)
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("URI = %s, want an otpauth://totp link", uri)
+	}
+	if u.Path != "/FC Tracker:coach@example.com" {
+		t.Errorf("label = %q", u.Path)
+	}
+
+	q := u.Query()
+	for key, want := range map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "FC Tracker",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
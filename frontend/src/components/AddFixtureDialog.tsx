@@ -13,7 +13,7 @@ import {
   Box,
   Alert,
 } from '@mui/material';
-import type { TPlayer, TTeam } from '@/types/types';
+import type { TOpponent, TPlayer, TTeam } from '@/types/types';
 import { authFetch } from '@/config/api';
 import { postcodeToCoordinates } from '@/utils/geocoding';
 
@@ -33,6 +33,7 @@ export default function AddFixtureDialog({ open, onClose, onSuccess }: AddFixtur
   const [postcode, setPostcode] = useState('');
   const [players, setPlayers] = useState<TPlayer[]>([]);
   const [teams, setTeams] = useState<TTeam[]>([]);
+  const [opponents, setOpponents] = useState<TOpponent[]>([]);
   const [submitting, setSubmitting] = useState(false);
   const [geocodingError, setGeocodingError] = useState<string | null>(null);
 
@@ -43,10 +44,12 @@ export default function AddFixtureDialog({ open, onClose, onSuccess }: AddFixtur
     Promise.all([
       authFetch('/api/player', opts).then(res => res.json()),
       authFetch('/api/team/getall', opts).then(res => res.json()),
+      authFetch('/api/opponent/getall', opts).then(res => res.json()),
     ])
-      .then(([playerData, teamData]) => {
+      .then(([playerData, teamData, opponentData]) => {
         setPlayers(playerData.players || []);
         setTeams(teamData.teams || []);
+        setOpponents(opponentData.opponents || []);
       })
       .catch((error) => {
         if (error.name !== 'AbortError') console.error('Error fetching dialog data:', error);
@@ -77,8 +80,8 @@ export default function AddFixtureDialog({ open, onClose, onSuccess }: AddFixtur
 
       const params = new URLSearchParams({
         date,
-        homeTeam,
-        awayTeam,
+        homeTeamId: homeTeam,
+        awayTeamId: awayTeam,
         homeScore,
         awayScore,
         manOfTheMatch,
@@ -150,10 +153,15 @@ export default function AddFixtureDialog({ open, onClose, onSuccess }: AddFixtur
             <InputLabel>Home Team</InputLabel>
             <Select value={homeTeam} onChange={(e) => setHomeTeam(e.target.value)}>
               {teams.map((team) => (
-                <MenuItem key={team.ID} value={team.Name}>
+                <MenuItem key={team.ID} value={team.ID}>
                   {team.Name}
                 </MenuItem>
               ))}
+              {opponents.map((opponent) => (
+                <MenuItem key={opponent.ID} value={opponent.ID}>
+                  {opponent.Name}
+                </MenuItem>
+              ))}
             </Select>
           </FormControl>
 
@@ -161,10 +169,15 @@ export default function AddFixtureDialog({ open, onClose, onSuccess }: AddFixtur
             <InputLabel>Away Team</InputLabel>
             <Select value={awayTeam} onChange={(e) => setAwayTeam(e.target.value)}>
               {teams.map((team) => (
-                <MenuItem key={team.ID} value={team.Name}>
+                <MenuItem key={team.ID} value={team.ID}>
                   {team.Name}
                 </MenuItem>
               ))}
+              {opponents.map((opponent) => (
+                <MenuItem key={opponent.ID} value={opponent.ID}>
+                  {opponent.Name}
+                </MenuItem>
+              ))}
             </Select>
           </FormControl>
 
@@ -23,7 +23,7 @@ interface AddPlayerDialogProps {
 
 export default function AddPlayerDialog({ open, onClose, onSuccess }: AddPlayerDialogProps) {
   const [name, setName] = useState('');
-  const [age, setAge] = useState('');
+  const [dob, setDob] = useState('');
   const [position, setPosition] = useState('');
   const [funFact, setFunFact] = useState('');
   const [teamName, setTeamName] = useState('');
@@ -43,7 +43,7 @@ export default function AddPlayerDialog({ open, onClose, onSuccess }: AddPlayerD
   }, [open]);
 
   const handleSubmit = async () => {
-    if (!name || !age || !position || !teamName) {
+    if (!name || !dob || !position || !teamName) {
       alert('Please fill in all required fields');
       return;
     }
@@ -53,7 +53,7 @@ export default function AddPlayerDialog({ open, onClose, onSuccess }: AddPlayerD
     try {
       const params = new URLSearchParams({
         name,
-        age,
+        dob,
         position,
         funFact,
         teamName,
@@ -68,7 +68,7 @@ export default function AddPlayerDialog({ open, onClose, onSuccess }: AddPlayerD
 
       if (response.ok) {
         setName('');
-        setAge('');
+        setDob('');
         setPosition('');
         setFunFact('');
         setTeamName('');
@@ -88,7 +88,7 @@ export default function AddPlayerDialog({ open, onClose, onSuccess }: AddPlayerD
   const handleClose = () => {
     if (!submitting) {
       setName('');
-      setAge('');
+      setDob('');
       setPosition('');
       setFunFact('');
       setTeamName('');
@@ -109,10 +109,11 @@ export default function AddPlayerDialog({ open, onClose, onSuccess }: AddPlayerD
             required
           />
           <TextField
-            label="Age"
-            type="number"
-            value={age}
-            onChange={(e) => setAge(e.target.value)}
+            label="Date of Birth"
+            type="date"
+            value={dob}
+            onChange={(e) => setDob(e.target.value)}
+            InputLabelProps={{ shrink: true }}
             fullWidth
             required
           />
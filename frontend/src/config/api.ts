@@ -19,14 +19,75 @@ export const buildApiUrl = (endpoint: string): string => {
   return `${API_BASE_URL}${endpoint}`;
 };
 
-// Authenticated fetch wrapper — attaches the JWT token from localStorage
-export const authFetch = (endpoint: string, options: RequestInit = {}): Promise<Response> => {
-  const token = localStorage.getItem('fctracker_token');
+export const TOKEN_KEY = 'fctracker_token';
+export const REFRESH_TOKEN_KEY = 'fctracker_refresh_token';
+
+// Fired when the session can't be refreshed and the user has to log in again
+export const SESSION_EXPIRED_EVENT = 'fctracker:session-expired';
+
+export const storeTokens = (token: string, refreshToken: string): void => {
+  localStorage.setItem(TOKEN_KEY, token);
+  localStorage.setItem(REFRESH_TOKEN_KEY, refreshToken);
+};
+
+export const clearTokens = (): void => {
+  localStorage.removeItem(TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+};
+
+// Only one refresh runs at a time. Refresh tokens are single use, so
+// parallel requests failing together must share the same refresh.
+let refreshing: Promise<boolean> | null = null;
+
+const refreshTokens = (): Promise<boolean> => {
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+      if (!refreshToken) return false;
+      try {
+        const res = await fetch(buildApiUrl('/api/auth/refresh'), {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+        if (!res.ok) return false;
+        const data = await res.json();
+        storeTokens(data.token, data.refresh_token);
+        return true;
+      } catch {
+        return false;
+      }
+    })().finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
+const withToken = (options: RequestInit): RequestInit => {
+  const token = localStorage.getItem(TOKEN_KEY);
   const headers = new Headers(options.headers);
   if (token) {
     headers.set('Authorization', `Bearer ${token}`);
   }
-  return fetch(buildApiUrl(endpoint), { ...options, headers });
+  return { ...options, headers };
+};
+
+// Authenticated fetch wrapper — attaches the JWT token from localStorage.
+// Access tokens are short lived, so on a 401 the tokens are refreshed and
+// the request is tried once more.
+export const authFetch = async (endpoint: string, options: RequestInit = {}): Promise<Response> => {
+  const res = await fetch(buildApiUrl(endpoint), withToken(options));
+  if (res.status !== 401 || !localStorage.getItem(REFRESH_TOKEN_KEY)) {
+    return res;
+  }
+
+  if (!(await refreshTokens())) {
+    clearTokens();
+    window.dispatchEvent(new Event(SESSION_EXPIRED_EVENT));
+    return res;
+  }
+  return fetch(buildApiUrl(endpoint), withToken(options));
 };
 
 // App configuration
@@ -1,6 +1,14 @@
 import { createContext, useContext, useState, useEffect, useCallback, useMemo } from 'react';
 import type { ReactNode } from 'react';
-import { buildApiUrl } from '@/config/api';
+import {
+  authFetch,
+  buildApiUrl,
+  clearTokens,
+  REFRESH_TOKEN_KEY,
+  SESSION_EXPIRED_EVENT,
+  storeTokens,
+  TOKEN_KEY,
+} from '@/config/api';
 import type { TUser } from '@/types/types';
 
 interface AuthContextType {
@@ -14,7 +22,6 @@ interface AuthContextType {
 
 const AuthContext = createContext<AuthContextType | undefined>(undefined);
 
-const TOKEN_KEY = 'fctracker_token';
 const USER_KEY = 'fctracker_user';
 
 export function AuthProvider({ children }: { children: ReactNode }) {
@@ -31,9 +38,8 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       return;
     }
 
-    fetch(buildApiUrl('/api/auth/me'), {
-      headers: { Authorization: `Bearer ${token}` },
-    })
+    // Goes through authFetch so an expired access token is refreshed
+    authFetch('/api/auth/me')
       .then((res) => {
         if (!res.ok) throw new Error('Invalid token');
         return res.json();
@@ -45,12 +51,23 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       .catch(() => {
         setToken(null);
         setUser(null);
-        localStorage.removeItem(TOKEN_KEY);
+        clearTokens();
         localStorage.removeItem(USER_KEY);
       })
       .finally(() => setLoading(false));
   }, [token]);
 
+  // authFetch gives up when the refresh token is expired or revoked
+  useEffect(() => {
+    const expired = () => {
+      localStorage.removeItem(USER_KEY);
+      setToken(null);
+      setUser(null);
+    };
+    window.addEventListener(SESSION_EXPIRED_EVENT, expired);
+    return () => window.removeEventListener(SESSION_EXPIRED_EVENT, expired);
+  }, []);
+
   const login = useCallback(async (email: string, password: string): Promise<string | null> => {
     try {
       const res = await fetch(buildApiUrl('/api/auth/login'), {
@@ -62,7 +79,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       const data = await res.json();
       if (!res.ok) return data.error || 'Login failed';
 
-      localStorage.setItem(TOKEN_KEY, data.token);
+      storeTokens(data.token, data.refresh_token);
       localStorage.setItem(USER_KEY, JSON.stringify(data.user));
       setToken(data.token);
       setUser(data.user);
@@ -83,7 +100,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       const data = await res.json();
       if (!res.ok) return data.error || 'Registration failed';
 
-      localStorage.setItem(TOKEN_KEY, data.token);
+      storeTokens(data.token, data.refresh_token);
       localStorage.setItem(USER_KEY, JSON.stringify(data.user));
       setToken(data.token);
       setUser(data.user);
@@ -94,7 +111,16 @@ export function AuthProvider({ children }: { children: ReactNode }) {
   }, []);
 
   const logout = useCallback(() => {
-    localStorage.removeItem(TOKEN_KEY);
+    // Revoke the session server side too, but don't wait for it
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    if (refreshToken) {
+      fetch(buildApiUrl('/api/auth/logout'), {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      }).catch(() => {});
+    }
+    clearTokens();
     localStorage.removeItem(USER_KEY);
     setToken(null);
     setUser(null);
@@ -8,7 +8,8 @@ export interface TUser {
 export interface TPlayer {
   ID: string;
   Name: string;
-  Age: string;
+  DOB: string | null; // ISO date
+  Age: number | null; // Worked out from DOB
   Position: string;
   FunFact: string;
   Goals: number;
@@ -26,7 +27,14 @@ export interface TTeam {
   ID: string;
   Name: string;
   Coach: string;
-  Founded: string;
+  Founded?: number;
+  Created: string;
+}
+
+// Opponent interface, a club whose fixtures we play but don't manage
+export interface TOpponent {
+  ID: string;
+  Name: string;
   Created: string;
 }
 
@@ -39,17 +47,49 @@ export interface TLocation {
 // Fixture interface (matches backend fields)
 export interface TFixture {
   ID: string;
-  Date: string;
+  Date: string; // ISO timestamp
+  HomeTeamID: string; // A team or an opponent
+  AwayTeamID: string;
   HomeTeam: string;
   AwayTeam: string;
-  HomeScore: string;
-  AwayScore: string;
+  HomeIsOpponent?: boolean;
+  AwayIsOpponent?: boolean;
+  SeasonID?: string;
+  CompetitionID?: string;
+  HomeScore: number | null; // Null until there is a result
+  AwayScore: number | null;
   ManOfTheMatch?: string;
   ManOfTheMatchName?: string;
   GoalScorers?: string[];
   GoalScorersNames?: string[];
   AssistScorers?: string[];
   AssistScorersNames?: string[];
+  Events?: TMatchEvent[]; // Ordered by minute
   Location?: TLocation;
 }
 
+export type TMatchEventType =
+  | "goal"
+  | "own_goal"
+  | "penalty_scored"
+  | "penalty_missed"
+  | "yellow_card"
+  | "second_yellow"
+  | "red_card"
+  | "substitution";
+
+export interface TMatchEvent {
+  ID: string;
+  Type: TMatchEventType;
+  Side: "home" | "away";
+  Minute: number;
+  AddedTime?: number; // Stoppage time, as in 90+3
+  PlayerID?: string; // Unset for opponents
+  PlayerName?: string;
+  AssistID?: string;
+  AssistName?: string;
+  SubstituteID?: string; // Player coming on
+  SubstituteName?: string;
+  Note?: string;
+}
+